@@ -0,0 +1,116 @@
+package proxywasm
+
+import (
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+)
+
+// AbiVersion identifies which revision of the proxy-wasm ABI a loaded module
+// speaks. Modules advertise this themselves by exporting a marker function
+// named after the version, e.g. proxy_abi_version_0_2_0; there is no runtime
+// negotiation.
+type AbiVersion int32
+
+const (
+	AbiVersion_UnknownOrNoAbiVersion AbiVersion = iota
+	AbiVersion_ProxyWasm_0_1_0
+	AbiVersion_ProxyWasm_0_2_0
+)
+
+const (
+	abiMarker_0_1_0 = "proxy_abi_version_0_1_0"
+	abiMarker_0_2_0 = "proxy_abi_version_0_2_0"
+)
+
+// defaultAbiVersion is used when a module exposes neither marker export.
+var defaultAbiVersion = AbiVersion_ProxyWasm_0_1_0
+
+// SetDefaultAbiVersion overrides the ABI version assumed for modules that
+// expose none of the known proxy_abi_version_* marker exports.
+func SetDefaultAbiVersion(v AbiVersion) {
+	defaultAbiVersion = v
+}
+
+func (v AbiVersion) String() string {
+	switch v {
+	case AbiVersion_ProxyWasm_0_1_0:
+		return "0.1.0"
+	case AbiVersion_ProxyWasm_0_2_0:
+		return "0.2.0"
+	default:
+		return "unknown"
+	}
+}
+
+// hasExport reports whether instance exposes an export named name, without
+// caring whether it is callable as a function — the marker exports carry no
+// meaningful signature, only their presence matters.
+func hasExport(instance types.WasmInstance, name string) bool {
+	if instance == nil {
+		return false
+	}
+	_, err := instance.GetExportsFunc(name)
+	return err == nil
+}
+
+// detectAbiVersion scans the module's exports for the proxy_abi_version_*
+// markers and returns the ABI version to dispatch proxy_on_* calls through.
+// A module exposing both markers is treated as 0.2.0 (the newest); a module
+// exposing neither falls back to defaultAbiVersion and logs a warning.
+func detectAbiVersion(instance types.WasmInstance) AbiVersion {
+	has01 := hasExport(instance, abiMarker_0_1_0)
+	has02 := hasExport(instance, abiMarker_0_2_0)
+
+	switch {
+	case has02:
+		return AbiVersion_ProxyWasm_0_2_0
+	case has01:
+		return AbiVersion_ProxyWasm_0_1_0
+	default:
+		log.DefaultLogger.Warnf("[proxywasm][abi] module exposes no proxy_abi_version_* marker export, "+
+			"falling back to default ABI version: %v", defaultAbiVersion)
+		return defaultAbiVersion
+	}
+}
+
+// newProxyWasmExports constructs the ProxyWasmExports implementation matching
+// abiVersion for instance. Both 0.1.0 and 0.2.0 modules share wasmContext's
+// call wiring except for the body callbacks, where 0.1.0 always passes an
+// end_of_stream of 0 (see proxyWasmExportsV01); they are split out so the
+// host-call signatures and behaviors that differ between the two ABIs can
+// diverge without an abiVersion switch at every call site.
+func newProxyWasmExports(abiVersion AbiVersion, ctx *wasmContext) ProxyWasmExports {
+	switch abiVersion {
+	case AbiVersion_ProxyWasm_0_2_0:
+		return &proxyWasmExportsV02{wasmContext: ctx}
+	default:
+		return &proxyWasmExportsV01{wasmContext: ctx}
+	}
+}
+
+// proxyWasmExportsV01 implements ProxyWasmExports for proxy-wasm ABI 0.1.0.
+type proxyWasmExportsV01 struct {
+	*wasmContext
+}
+
+// proxy_on_request_body and proxy_on_response_body override the embedded
+// wasmContext's implementation: ABI 0.1.0 predates the end_of_stream flag on
+// body callbacks (added in 0.2.0), so 0.1.0 modules were never told whether a
+// given chunk was the last one and always saw 0 here. Preserve that for
+// modules that detect as 0.1.0 rather than silently handing them a flag their
+// ABI doesn't define.
+func (wasm *proxyWasmExportsV01) proxy_on_request_body(contextId int32, bodyBufferLength int32, endOfStream int32) (int32, error) {
+	return wasm.callI32("proxy_on_request_body", contextId, bodyBufferLength, 0)
+}
+
+func (wasm *proxyWasmExportsV01) proxy_on_response_body(contextId int32, bodyBufferLength int32, endOfStream int32) (int32, error) {
+	return wasm.callI32("proxy_on_response_body", contextId, bodyBufferLength, 0)
+}
+
+// proxyWasmExportsV02 implements ProxyWasmExports for proxy-wasm ABI 0.2.0. It
+// embeds the same call wiring as 0.1.0 for every export except the body
+// callbacks above, where it uses wasmContext's behavior unmodified (forwarding
+// the real end_of_stream flag) since 0.2.0 is where that flag was introduced.
+type proxyWasmExportsV02 struct {
+	*wasmContext
+}
@@ -0,0 +1,161 @@
+package proxywasm
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"mosn.io/mosn/pkg/types"
+)
+
+// HttpCallResponseCallback is invoked when the host call dispatched for an
+// HTTP call token completes, mirroring proxy_on_http_call_response.
+type HttpCallResponseCallback func(headers, body, trailers int32)
+
+// GrpcCallResponseCallback is invoked when the host call dispatched for a
+// gRPC call token completes, mirroring proxy_on_grpc_receive/close.
+type GrpcCallResponseCallback func(responseSize int32, statusCode int32)
+
+// ABIContextWrapper is the single object filters in this package obtain from
+// a wasm plugin instance: it pairs an ABI-specific ProxyWasmExports (v0.1.0
+// or v0.2.0, see abi.go) with the generic types.WasmInstance it calls into,
+// plus the host-side state a wasm instance needs across the lifetime of a
+// request — context id allocation, outstanding HTTP/gRPC call tokens, shared
+// queues, and registered foreign functions. Filters never reach into a raw
+// *wasm.Instance directly; everything goes through here, which is what lets
+// the wasmer and wazero engines (pkg/wasm/wasmer, pkg/wasm/wazero) and the
+// ABI versions be mixed without the filter caring.
+type ABIContextWrapper struct {
+	ProxyWasmExports
+
+	instance types.WasmInstance
+
+	mu sync.Mutex
+
+	contextIdSeq     int32
+	httpCallToken    int32
+	httpCallbacks    map[int32]HttpCallResponseCallback
+	grpcCallToken    int32
+	grpcCallbacks    map[int32]GrpcCallResponseCallback
+	sharedQueueToken int32
+	sharedQueues     map[string]int32
+	foreignFuncs     map[int32]func(data []byte) []byte
+
+	// effectiveContextId is the contextId proxy_set_effective_context last
+	// switched host calls to; it defaults to the context this wrapper was
+	// built for. See host_calls.go.
+	effectiveContextId int32
+
+	// RequestHeaders/RequestTrailers/ResponseHeaders/ResponseTrailers back
+	// proxy_get_header_map_value (host_calls.go) for this context. The filter
+	// populates them before invoking the matching proxy_on_request_*/
+	// proxy_on_response_* export.
+	RequestHeaders   map[string]string
+	RequestTrailers  map[string]string
+	ResponseHeaders  map[string]string
+	ResponseTrailers map[string]string
+
+	// LocalResponse records the last call to proxy_send_local_response, nil
+	// until one is made. The filter reads it after an export call returns to
+	// decide whether to short-circuit the request with this response instead
+	// of continuing the filter chain.
+	LocalResponse *LocalResponse
+}
+
+// LocalResponse is what proxy_send_local_response was called with.
+type LocalResponse struct {
+	StatusCode int32
+	Body       string
+	Headers    map[string]string
+}
+
+// NewABIContextWrapper builds the wrapper for a freshly created instance,
+// detecting its ABI version and selecting the matching ProxyWasmExports.
+func NewABIContextWrapper(root *rootContext, contextId int32, filter *streamProxyWasmFilter, instance types.WasmInstance) *ABIContextWrapper {
+	a := &ABIContextWrapper{
+		ProxyWasmExports:   newWasmContext(root, contextId, filter, instance),
+		instance:           instance,
+		httpCallbacks:      make(map[int32]HttpCallResponseCallback),
+		grpcCallbacks:      make(map[int32]GrpcCallResponseCallback),
+		sharedQueues:       make(map[string]int32),
+		foreignFuncs:       make(map[int32]func(data []byte) []byte),
+		effectiveContextId: contextId,
+		RequestHeaders:     map[string]string{},
+		RequestTrailers:    map[string]string{},
+		ResponseHeaders:    map[string]string{},
+		ResponseTrailers:   map[string]string{},
+	}
+
+	if setter, ok := a.ProxyWasmExports.(hostCallHandlerSetter); ok {
+		setter.setHostCallHandler(a)
+	}
+
+	return a
+}
+
+// Instance returns the generic engine instance backing this wrapper, for the
+// rare host-call sites (e.g. proxy_get_header_map_value) that need to read or
+// write wasm linear memory directly rather than invoke an export.
+func (a *ABIContextWrapper) Instance() types.WasmInstance {
+	return a.instance
+}
+
+// NextContextId allocates a new, unique context id scoped to this instance.
+func (a *ABIContextWrapper) NextContextId() int32 {
+	return atomic.AddInt32(&a.contextIdSeq, 1)
+}
+
+// RegisterHttpCall allocates a call token for an outbound HTTP call and
+// remembers cb so ResolveHttpCall can invoke it once the host dispatches
+// proxy_on_http_call_response for that token.
+func (a *ABIContextWrapper) RegisterHttpCall(cb HttpCallResponseCallback) int32 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.httpCallToken++
+	token := a.httpCallToken
+	a.httpCallbacks[token] = cb
+	return token
+}
+
+// ResolveHttpCall looks up and removes the callback registered for token.
+func (a *ABIContextWrapper) ResolveHttpCall(token int32) (HttpCallResponseCallback, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cb, ok := a.httpCallbacks[token]
+	delete(a.httpCallbacks, token)
+	return cb, ok
+}
+
+// RegisterGrpcCall allocates a call token for an outbound gRPC call, mirroring
+// RegisterHttpCall.
+func (a *ABIContextWrapper) RegisterGrpcCall(cb GrpcCallResponseCallback) int32 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.grpcCallToken++
+	token := a.grpcCallToken
+	a.grpcCallbacks[token] = cb
+	return token
+}
+
+// ResolveGrpcCall looks up and removes the callback registered for token.
+func (a *ABIContextWrapper) ResolveGrpcCall(token int32) (GrpcCallResponseCallback, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cb, ok := a.grpcCallbacks[token]
+	delete(a.grpcCallbacks, token)
+	return cb, ok
+}
+
+// RegisterForeignFunction registers fn under a new functionId for later
+// dispatch from proxy_on_foreign_function host-side triggers.
+func (a *ABIContextWrapper) RegisterForeignFunction(fn func(data []byte) []byte) int32 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	id := int32(len(a.foreignFuncs)) + 1
+	a.foreignFuncs[id] = fn
+	return id
+}
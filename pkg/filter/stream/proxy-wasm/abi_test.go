@@ -0,0 +1,143 @@
+package proxywasm
+
+import (
+	"errors"
+	"testing"
+
+	"mosn.io/mosn/pkg/types"
+)
+
+// fakeInstance is a minimal types.WasmInstance stand-in: embedding the
+// interface satisfies every method we don't override, while GetExportsFunc
+// reports presence from a plain set of export names, which is all
+// detectAbiVersion/hasExport ever look at.
+type fakeInstance struct {
+	types.WasmInstance
+	exports map[string]bool
+}
+
+func (f *fakeInstance) GetExportsFunc(name string) (types.WasmFunction, error) {
+	if f.exports[name] {
+		return nil, nil
+	}
+	return nil, errors.New("export not found")
+}
+
+func TestHasExport(t *testing.T) {
+	instance := &fakeInstance{exports: map[string]bool{abiMarker_0_1_0: true}}
+
+	if !hasExport(instance, abiMarker_0_1_0) {
+		t.Errorf("hasExport(%s) = false, want true", abiMarker_0_1_0)
+	}
+	if hasExport(instance, abiMarker_0_2_0) {
+		t.Errorf("hasExport(%s) = true, want false", abiMarker_0_2_0)
+	}
+	if hasExport(nil, abiMarker_0_1_0) {
+		t.Error("hasExport(nil, ...) = true, want false")
+	}
+}
+
+func TestDetectAbiVersion(t *testing.T) {
+	defer func(prev AbiVersion) { defaultAbiVersion = prev }(defaultAbiVersion)
+
+	tests := []struct {
+		name    string
+		exports map[string]bool
+		want    AbiVersion
+	}{
+		{"both markers prefers 0.2.0", map[string]bool{abiMarker_0_1_0: true, abiMarker_0_2_0: true}, AbiVersion_ProxyWasm_0_2_0},
+		{"only 0.2.0", map[string]bool{abiMarker_0_2_0: true}, AbiVersion_ProxyWasm_0_2_0},
+		{"only 0.1.0", map[string]bool{abiMarker_0_1_0: true}, AbiVersion_ProxyWasm_0_1_0},
+		{"no markers falls back to default", map[string]bool{}, defaultAbiVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := &fakeInstance{exports: tt.exports}
+			if got := detectAbiVersion(instance); got != tt.want {
+				t.Errorf("detectAbiVersion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectAbiVersionHonorsOverriddenDefault(t *testing.T) {
+	defer func(prev AbiVersion) { defaultAbiVersion = prev }(defaultAbiVersion)
+
+	SetDefaultAbiVersion(AbiVersion_ProxyWasm_0_2_0)
+
+	got := detectAbiVersion(&fakeInstance{exports: map[string]bool{}})
+	if got != AbiVersion_ProxyWasm_0_2_0 {
+		t.Errorf("detectAbiVersion() = %v, want overridden default %v", got, AbiVersion_ProxyWasm_0_2_0)
+	}
+}
+
+// recordingFunc implements types.WasmFunction, recording the args it was
+// last called with so tests can assert on what a ProxyWasmExports
+// implementation actually passed through to an export.
+type recordingFunc struct {
+	lastArgs []int32
+}
+
+func (f *recordingFunc) Call(args ...int32) (int32, error) {
+	f.lastArgs = args
+	return 0, nil
+}
+
+// bodyCallInstance is a fakeInstance whose GetExportsFunc hands back a
+// recordingFunc for the named body export, so TestBodyCallbacksEndOfStream
+// can inspect what endOfStream value each ABI version forwarded.
+type bodyCallInstance struct {
+	fakeInstance
+	fn *recordingFunc
+}
+
+func (f *bodyCallInstance) GetExportsFunc(name string) (types.WasmFunction, error) {
+	return f.fn, nil
+}
+
+func TestBodyCallbacksEndOfStream(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  ProxyWasmExports
+		want int32
+	}{
+		{"0.1.0 always forwards 0", &proxyWasmExportsV01{wasmContext: &wasmContext{instance: &bodyCallInstance{fn: &recordingFunc{}}}}, 0},
+		{"0.2.0 forwards the real flag", &proxyWasmExportsV02{wasmContext: &wasmContext{instance: &bodyCallInstance{fn: &recordingFunc{}}}}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.ctx.proxy_on_request_body(1, 10, 1); err != nil {
+				t.Fatalf("proxy_on_request_body() error = %v", err)
+			}
+
+			var rec *recordingFunc
+			switch v := tt.ctx.(type) {
+			case *proxyWasmExportsV01:
+				rec = v.instance.(*bodyCallInstance).fn
+			case *proxyWasmExportsV02:
+				rec = v.instance.(*bodyCallInstance).fn
+			}
+
+			got := rec.lastArgs[len(rec.lastArgs)-1]
+			if got != tt.want {
+				t.Errorf("endOfStream passed to export = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAbiVersionString(t *testing.T) {
+	tests := map[AbiVersion]string{
+		AbiVersion_ProxyWasm_0_1_0:       "0.1.0",
+		AbiVersion_ProxyWasm_0_2_0:       "0.2.0",
+		AbiVersion_UnknownOrNoAbiVersion: "unknown",
+	}
+
+	for version, want := range tests {
+		if got := version.String(); got != want {
+			t.Errorf("AbiVersion(%d).String() = %q, want %q", version, got, want)
+		}
+	}
+}
@@ -2,9 +2,11 @@ package proxywasm
 
 import (
 	"errors"
+	"sync/atomic"
 
-	wasm "github.com/wasmerio/go-ext-wasm/wasmer"
 	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+	mwasm "mosn.io/mosn/pkg/wasm"
 )
 
 type ProxyWasmExports interface {
@@ -48,7 +50,6 @@ type ProxyWasmExports interface {
 	proxy_on_foreign_function(rootContextId int32, functionId int32, dataSize int32) error
 }
 
-
 type rootContext struct {
 	config *StreamProxyWasmConfig
 
@@ -58,346 +59,239 @@ type rootContext struct {
 	//contextId    uint32
 
 	wasmCode      []byte
-	wasmModule    wasm.Module
-	wasiVersion   wasm.WasiVersion
-	wasmImportObj *wasm.ImportObject
+	wasmModule    types.WasmModule
+	wasiVersion   int32
+	wasmImportObj interface{}
+
+	// abiVersion is detected from the first instance's proxy_abi_version_*
+	// marker exports, cached here for every later instance of the module, and
+	// drives which ProxyWasmExports implementation (v0.1.0 vs v0.2.0) each
+	// instance's wasmContext is built with.
+	abiVersion AbiVersion
 }
 
+// wasmContext calls a single types.WasmInstance's proxy_on_* exports. It no
+// longer knows which engine produced the instance: instance is the abstract
+// types.WasmInstance wrapper, so the same wasmContext works unmodified
+// whether the module was compiled by the wasmer or wazero engine. Per-request
+// host state (context id allocation, call token tables, shared queues,
+// foreign functions) lives on the owning ABIContextWrapper rather than here.
 type wasmContext struct {
 	contextId int32
 	filter    *streamProxyWasmFilter
-	instance  *wasm.Instance
+	instance  types.WasmInstance
+
+	// hostHandler is the ABIContextWrapper this wasmContext backs, installed
+	// by NewABIContextWrapper via setHostCallHandler. call0/callI32 mark it
+	// active on instance immediately before invoking an export so any proxy_*
+	// host call the guest makes reentrantly during that export resolves back
+	// to this context's state (see host_calls.go).
+	hostHandler mwasm.HostCallHandler
 }
 
-func (wasm *wasmContext) _start() error {
-	log.DefaultLogger.Debugf("wasm call exported func: _start")
-	ff := wasm.instance.Exports["_start"]
-	if ff == nil {
-		return errors.New("func _start not found")
+// setHostCallHandler implements hostCallHandlerSetter.
+func (wasm *wasmContext) setHostCallHandler(h mwasm.HostCallHandler) {
+	wasm.hostHandler = h
+}
+
+// newWasmContext detects the instance's ABI version and builds the matching
+// ProxyWasmExports implementation for it. root.abiVersion is cached so
+// repeated instances of the same module don't re-scan exports. root is shared
+// by every pooled instance of the plugin, so concurrent requests may race to
+// detect and cache it here; abiVersion is read/written with atomic ops
+// (rather than a lock) to keep the common already-cached case cheap.
+func newWasmContext(root *rootContext, contextId int32, filter *streamProxyWasmFilter, instance types.WasmInstance) ProxyWasmExports {
+	abiPtr := (*int32)(&root.abiVersion)
+
+	abiVersion := AbiVersion(atomic.LoadInt32(abiPtr))
+	if abiVersion == AbiVersion_UnknownOrNoAbiVersion {
+		detected := detectAbiVersion(instance)
+		if atomic.CompareAndSwapInt32(abiPtr, int32(AbiVersion_UnknownOrNoAbiVersion), int32(detected)) {
+			log.DefaultLogger.Infof("[proxywasm][abi] detected proxy-wasm ABI version: %v", detected)
+		}
+		abiVersion = AbiVersion(atomic.LoadInt32(abiPtr))
 	}
-	_, err := ff()
-	return err
+
+	ctx := &wasmContext{contextId: contextId, filter: filter, instance: instance}
+
+	return newProxyWasmExports(abiVersion, ctx)
 }
 
-func (wasm *wasmContext) proxy_on_context_create(contextId int32, parentContextId int32) error {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_context_create")
-	ff := wasm.instance.Exports["proxy_on_context_create"]
-	if ff == nil {
-		return errors.New("func proxy_on_context_create not found")
+// call0 invokes the named export taking args and discarding its return value.
+func (wasm *wasmContext) call0(name string, args ...int32) error {
+	log.DefaultLogger.Debugf("wasm call exported func: %v", name)
+
+	markActive(wasm.instance, wasm.hostHandler)
+
+	fn, err := wasm.instance.GetExportsFunc(name)
+	if err != nil {
+		wasm.reportInvocationResult(false)
+		return errors.New("func " + name + " not found")
 	}
-	_, err := ff(contextId, parentContextId)
+
+	_, err = fn.Call(args...)
+	wasm.reportTrap(name, err)
+	wasm.reportInvocationResult(err == nil)
 	return err
 }
 
-func (wasm *wasmContext) proxy_on_vm_start(rootContextId int32, configurationSize int32) (int32, error) {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_vm_start")
-	ff := wasm.instance.Exports["proxy_on_vm_start"]
-	if ff == nil {
-		return 0, errors.New("func proxy_on_vm_start not found")
-	}
-	res, err := ff(rootContextId, configurationSize)
+// callI32 invokes the named export taking args and returns its int32 result.
+func (wasm *wasmContext) callI32(name string, args ...int32) (int32, error) {
+	log.DefaultLogger.Debugf("wasm call exported func: %v", name)
+
+	markActive(wasm.instance, wasm.hostHandler)
+
+	fn, err := wasm.instance.GetExportsFunc(name)
 	if err != nil {
-		return 0, err
+		wasm.reportInvocationResult(false)
+		return 0, errors.New("func " + name + " not found")
 	}
-	return res.ToI32(), nil
+
+	res, err := fn.Call(args...)
+	wasm.reportTrap(name, err)
+	wasm.reportInvocationResult(err == nil)
+	return res, err
 }
 
+// reportTrap notifies the owning filter when err is a trap, so the instance
+// can be retired instead of silently left in a (possibly corrupted) state.
+// Any other error from a call is expected to be handled by the caller as
+// usual and is not reported here.
+func (wasm *wasmContext) reportTrap(export string, err error) {
+	trapErr, ok := err.(*types.TrapError)
+	if !ok || trapErr == nil {
+		return
+	}
+
+	log.DefaultLogger.Errorf("[proxywasm] export %v trapped: %v", export, trapErr)
 
-func (wasm *wasmContext) proxy_on_done(contextId int32) (int32, error) {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_done")
-	ff := wasm.instance.Exports["proxy_on_done"]
-	if ff == nil {
-		return 0, errors.New("func proxy_on_done not found")
+	if wasm.filter != nil {
+		wasm.filter.onInstanceTrapped(wasm.instance, trapErr)
 	}
-	res, err := ff(contextId)
-	if err != nil {
-		return 0, err
+}
+
+// reportInvocationResult feeds this call's outcome into the owning filter's
+// plugin wrapper (see streamProxyWasmFilter.onCallCompleted), so a Canary
+// rollout's HealthChecker observes real request outcomes instead of staying
+// permanently unfed.
+func (wasm *wasmContext) reportInvocationResult(success bool) {
+	if wasm.filter != nil {
+		wasm.filter.onCallCompleted(success)
 	}
-	return res.ToI32(), nil
+}
+
+func (wasm *wasmContext) _start() error {
+	return wasm.call0("_start")
+}
+
+func (wasm *wasmContext) proxy_on_context_create(contextId int32, parentContextId int32) error {
+	return wasm.call0("proxy_on_context_create", contextId, parentContextId)
+}
+
+func (wasm *wasmContext) proxy_on_vm_start(rootContextId int32, configurationSize int32) (int32, error) {
+	return wasm.callI32("proxy_on_vm_start", rootContextId, configurationSize)
+}
+
+func (wasm *wasmContext) proxy_on_done(contextId int32) (int32, error) {
+	return wasm.callI32("proxy_on_done", contextId)
 }
 
 func (wasm *wasmContext) proxy_on_log(contextId int32) error {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_log")
-	ff := wasm.instance.Exports["proxy_on_log"]
-	if ff == nil {
-		return errors.New("func proxy_on_log not found")
-	}
-	_, err := ff(contextId)
-	return err
+	return wasm.call0("proxy_on_log", contextId)
 }
 
 func (wasm *wasmContext) proxy_on_delete(contextId int32) error {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_delete")
-	ff := wasm.instance.Exports["proxy_on_delete"]
-	if ff == nil {
-		return errors.New("func proxy_on_delete not found")
-	}
-	_, err := ff(contextId)
-	return err
+	return wasm.call0("proxy_on_delete", contextId)
 }
 
 func (wasm *wasmContext) proxy_on_configure(rootContextId int32, configurationSize int32) (int32, error) {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_configure")
-	ff := wasm.instance.Exports["proxy_on_configure"]
-	if ff == nil {
-		return 0, errors.New("func proxy_on_configure not found")
-	}
-	res, err := ff(rootContextId, configurationSize)
-	if err != nil {
-		return 0, err
-	}
-	return res.ToI32(), nil
+	return wasm.callI32("proxy_on_configure", rootContextId, configurationSize)
 }
 
 func (wasm *wasmContext) proxy_on_tick(rootContextId int32) error {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_tick")
-	ff := wasm.instance.Exports["proxy_on_tick"]
-	if ff == nil {
-		return errors.New("func proxy_on_tick not found")
-	}
-	_, err := ff(rootContextId)
-	return err
+	return wasm.call0("proxy_on_tick", rootContextId)
 }
 
 func (wasm *wasmContext) proxy_on_new_connection(contextId int32) error {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_new_connection")
-	ff := wasm.instance.Exports["proxy_on_new_connection"]
-	if ff == nil {
-		return errors.New("func proxy_on_new_connection not found")
-	}
-	_, err := ff(contextId)
-	return err
+	return wasm.call0("proxy_on_new_connection", contextId)
 }
 
 func (wasm *wasmContext) proxy_on_downstream_data(contextId int32, dataLength int32, endOfStream int32) (int32, error) {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_downstream_data")
-	ff := wasm.instance.Exports["proxy_on_downstream_data"]
-	if ff == nil {
-		return 0, errors.New("func proxy_on_downstream_data not found")
-	}
-	res, err := ff(contextId, dataLength, endOfStream)
-	if err != nil {
-		return 0, err
-	}
-	return res.ToI32(), nil
+	return wasm.callI32("proxy_on_downstream_data", contextId, dataLength, endOfStream)
 }
 
 func (wasm *wasmContext) proxy_on_downstream_connection_close(contextId int32, closeType int32) error {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_downstream_connection_close")
-	ff := wasm.instance.Exports["proxy_on_downstream_connection_close"]
-	if ff == nil {
-		return errors.New("func proxy_on_downstream_connection_close not found")
-	}
-	_, err := ff(contextId, closeType)
-	return err
+	return wasm.call0("proxy_on_downstream_connection_close", contextId, closeType)
 }
 
 func (wasm *wasmContext) proxy_on_upstream_data(contextId int32, dataLength int32, endOfStream int32) (int32, error) {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_upstream_data")
-	ff := wasm.instance.Exports["proxy_on_upstream_data"]
-	if ff == nil {
-		return 0, errors.New("func proxy_on_upstream_data not found")
-	}
-	res, err := ff(contextId, dataLength, endOfStream)
-	if err != nil {
-		return 0, err
-	}
-	return res.ToI32(), nil
+	return wasm.callI32("proxy_on_upstream_data", contextId, dataLength, endOfStream)
 }
 
 func (wasm *wasmContext) proxy_on_upstream_connection_close(contextId int32, closeType int32) error {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_upstream_connection_close")
-	ff := wasm.instance.Exports["proxy_on_upstream_connection_close"]
-	if ff == nil {
-		return errors.New("func proxy_on_upstream_connection_close not found")
-	}
-	_, err := ff(contextId, closeType)
-	return err
+	return wasm.call0("proxy_on_upstream_connection_close", contextId, closeType)
 }
 
 func (wasm *wasmContext) proxy_on_request_headers(contextId int32, headers int32, endOfStream int32) (int32, error) {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_request_headers")
-	ff := wasm.instance.Exports["proxy_on_request_headers"]
-	if ff == nil {
-		return 0, errors.New("func proxy_on_request_headers not found")
-	}
-	res, err := ff(contextId, headers, endOfStream)
-	if err != nil {
-		return 0, err
-	}
-	return res.ToI32(), nil
+	return wasm.callI32("proxy_on_request_headers", contextId, headers, endOfStream)
 }
 
 func (wasm *wasmContext) proxy_on_request_body(contextId int32, bodyBufferLength int32, endOfStream int32) (int32, error) {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_request_body")
-	ff := wasm.instance.Exports["proxy_on_request_body"]
-	if ff == nil {
-		return 0, errors.New("func proxy_on_request_body not found")
-	}
-	res, err := ff(contextId, bodyBufferLength, endOfStream)
-	if err != nil {
-		return 0, err
-	}
-	return res.ToI32(), nil
+	return wasm.callI32("proxy_on_request_body", contextId, bodyBufferLength, endOfStream)
 }
 
 func (wasm *wasmContext) proxy_on_request_trailers(contextId int32, trailers int32) (int32, error) {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_request_trailers")
-	ff := wasm.instance.Exports["proxy_on_request_trailers"]
-	if ff == nil {
-		return 0, errors.New("func proxy_on_request_trailers not found")
-	}
-	res, err := ff(contextId, trailers)
-	if err != nil {
-		return 0, err
-	}
-	return res.ToI32(), nil
+	return wasm.callI32("proxy_on_request_trailers", contextId, trailers)
 }
 
 func (wasm *wasmContext) proxy_on_request_metadata(contextId int32, nElements int32) (int32, error) {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_request_metadata")
-	ff := wasm.instance.Exports["proxy_on_request_metadata"]
-	if ff == nil {
-		return 0, errors.New("func proxy_on_request_metadata not found")
-	}
-	res, err := ff(contextId, nElements)
-	if err != nil {
-		return 0, err
-	}
-	return res.ToI32(), nil
+	return wasm.callI32("proxy_on_request_metadata", contextId, nElements)
 }
 
 func (wasm *wasmContext) proxy_on_response_headers(contextId int32, headers int32, endOfStream int32) (int32, error) {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_response_headers")
-	ff := wasm.instance.Exports["proxy_on_response_headers"]
-	if ff == nil {
-		return 0, errors.New("func proxy_on_response_headers not found")
-	}
-	res, err := ff(contextId, headers, endOfStream)
-	if err != nil {
-		return 0, err
-	}
-	return res.ToI32(), nil
+	return wasm.callI32("proxy_on_response_headers", contextId, headers, endOfStream)
 }
 
 func (wasm *wasmContext) proxy_on_response_body(contextId int32, bodyBufferLength int32, endOfStream int32) (int32, error) {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_response_body")
-	ff := wasm.instance.Exports["proxy_on_response_body"]
-	if ff == nil {
-		return 0, errors.New("func proxy_on_response_body not found")
-	}
-	res, err := ff(contextId, bodyBufferLength, endOfStream)
-	if err != nil {
-		return 0, err
-	}
-	return res.ToI32(), nil
+	return wasm.callI32("proxy_on_response_body", contextId, bodyBufferLength, endOfStream)
 }
 
 func (wasm *wasmContext) proxy_on_response_trailers(contextId int32, trailers int32) (int32, error) {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_response_trailers")
-	ff := wasm.instance.Exports["proxy_on_response_trailers"]
-	if ff == nil {
-		return 0, errors.New("func proxy_on_response_trailers not found")
-	}
-	res, err := ff(contextId, trailers)
-	if err != nil {
-		return 0, err
-	}
-	return res.ToI32(), nil
+	return wasm.callI32("proxy_on_response_trailers", contextId, trailers)
 }
 
 func (wasm *wasmContext) proxy_on_response_metadata(contextId int32, nElements int32) (int32, error) {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_response_metadata")
-	ff := wasm.instance.Exports["proxy_on_response_metadata"]
-	if ff == nil {
-		return 0, errors.New("func proxy_on_response_metadata not found")
-	}
-	res, err := ff(contextId, nElements)
-	if err != nil {
-		return 0, err
-	}
-	return res.ToI32(), nil
+	return wasm.callI32("proxy_on_response_metadata", contextId, nElements)
 }
 
 func (wasm *wasmContext) proxy_on_http_call_response(contextId int32, token int32, headers int32, bodySize int32, trailers int32) error {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_http_call_response")
-	ff := wasm.instance.Exports["proxy_on_http_call_response"]
-	if ff == nil {
-		return errors.New("func proxy_on_http_call_response not found")
-	}
-	_, err := ff(contextId, token, headers, bodySize, trailers)
-	return err
+	return wasm.call0("proxy_on_http_call_response", contextId, token, headers, bodySize, trailers)
 }
 
 func (wasm *wasmContext) proxy_on_grpc_receive_initial_metadata(contextId int32, token int32, headers int32) error {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_grpc_receive_initial_metadata")
-	ff := wasm.instance.Exports["proxy_on_grpc_receive_initial_metadata"]
-	if ff == nil {
-		return errors.New("func proxy_on_grpc_receive_initial_metadata not found")
-	}
-	_, err := ff(contextId, token, headers)
-	return err
+	return wasm.call0("proxy_on_grpc_receive_initial_metadata", contextId, token, headers)
 }
 
 func (wasm *wasmContext) proxy_on_grpc_trailing_metadata(contextId int32, token int32, trailers int32) error {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_grpc_trailing_metadata")
-	ff := wasm.instance.Exports["proxy_on_grpc_trailing_metadata"]
-	if ff == nil {
-		return errors.New("func proxy_on_grpc_trailing_metadata not found")
-	}
-	_, err := ff(contextId, token, trailers)
-	return err
+	return wasm.call0("proxy_on_grpc_trailing_metadata", contextId, token, trailers)
 }
 
 func (wasm *wasmContext) proxy_on_grpc_receive(contextId int32, token int32, responseSize int32) error {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_grpc_receive")
-	ff := wasm.instance.Exports["proxy_on_grpc_receive"]
-	if ff == nil {
-		return errors.New("func proxy_on_grpc_receive not found")
-	}
-	_, err := ff(contextId, token, responseSize)
-	return err
+	return wasm.call0("proxy_on_grpc_receive", contextId, token, responseSize)
 }
 
 func (wasm *wasmContext) proxy_on_grpc_close(contextId int32, token int32, statusCode int32) error {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_grpc_close")
-	ff := wasm.instance.Exports["proxy_on_grpc_close"]
-	if ff == nil {
-		return errors.New("func proxy_on_grpc_close not found")
-	}
-	_, err := ff(contextId, token, statusCode)
-	return err
+	return wasm.call0("proxy_on_grpc_close", contextId, token, statusCode)
 }
 
 func (wasm *wasmContext) proxy_on_queue_ready(rootContextId int32, token int32) error {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_queue_ready")
-	ff := wasm.instance.Exports["proxy_on_queue_ready"]
-	if ff == nil {
-		return errors.New("func proxy_on_queue_ready not found")
-	}
-	_, err := ff(rootContextId, token)
-	return err
+	return wasm.call0("proxy_on_queue_ready", rootContextId, token)
 }
 
 func (wasm *wasmContext) proxy_validate_configuration(rootContextId int32, configurationSize int32) (int32, error) {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_validate_configuration")
-	ff := wasm.instance.Exports["proxy_validate_configuration"]
-	if ff == nil {
-		return 0, errors.New("func proxy_validate_configuration not found")
-	}
-	res, err := ff(rootContextId, configurationSize)
-	if err != nil {
-		return 0, err
-	}
-	return res.ToI32(), nil
+	return wasm.callI32("proxy_validate_configuration", rootContextId, configurationSize)
 }
 
 func (wasm *wasmContext) proxy_on_foreign_function(rootContextId int32, functionId int32, dataSize int32) error {
-	log.DefaultLogger.Debugf("wasm call exported func: proxy_on_foreign_function")
-	ff := wasm.instance.Exports["proxy_on_foreign_function"]
-	if ff == nil {
-		return errors.New("func proxy_on_foreign_function not found")
-	}
-	_, err := ff(rootContextId, functionId, dataSize)
-	return err
-}
\ No newline at end of file
+	return wasm.call0("proxy_on_foreign_function", rootContextId, functionId, dataSize)
+}
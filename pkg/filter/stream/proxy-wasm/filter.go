@@ -0,0 +1,95 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxywasm
+
+import (
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+	mwasm "mosn.io/mosn/pkg/wasm"
+)
+
+// StreamProxyWasmConfig names the plugin (registered with
+// pkg/wasm.GetWasmManager) a streamProxyWasmFilter dispatches proxy_on_*
+// calls into.
+type StreamProxyWasmConfig struct {
+	PluginName string
+}
+
+// streamProxyWasmFilter drives a single request's proxy-wasm contexts
+// against its configured plugin's pooled instances.
+type streamProxyWasmFilter struct {
+	config *StreamProxyWasmConfig
+}
+
+// instanceTrapReporter is implemented by the types.WasmPlugin that owns a
+// trapped instance's pool (wasmPluginImpl, in pkg/wasm). It is declared here,
+// rather than imported, so this package only depends on pkg/wasm through the
+// already-exported GetWasmManager/WasmPluginWrapper surface.
+type instanceTrapReporter interface {
+	ReportTrap(instance types.WasmInstance, trapErr error)
+}
+
+// onInstanceTrapped retires instance from its owning plugin's pool after a
+// TrapError, so a single misbehaving module instance cannot keep breaking
+// request handling for every other request routed to that plugin.
+func (f *streamProxyWasmFilter) onInstanceTrapped(instance types.WasmInstance, trapErr *types.TrapError) {
+	if f == nil || f.config == nil || f.config.PluginName == "" {
+		return
+	}
+
+	wrapper := mwasm.GetWasmManager().GetWasmPluginWrapperByName(f.config.PluginName)
+	if wrapper == nil {
+		return
+	}
+
+	reporter, ok := wrapper.GetPlugin().(instanceTrapReporter)
+	if !ok {
+		log.DefaultLogger.Errorf("[proxywasm] plugin %v does not support ReportTrap", f.config.PluginName)
+		return
+	}
+
+	reporter.ReportTrap(instance, trapErr)
+}
+
+// invocationResultRecorder is implemented by the types.WasmPluginWrapper
+// that backs real plugins (pluginWrapper, in pkg/wasm). Declared here,
+// rather than imported, for the same reason as instanceTrapReporter above.
+type invocationResultRecorder interface {
+	RecordInvocationResult(success bool)
+}
+
+// onCallCompleted feeds a single export call's outcome into the plugin's
+// in-flight Canary rollout (if any), so repeated real export failures while a
+// canary is live can trip its HealthChecker and trigger an automatic
+// rollback instead of that health window staying permanently unfed. See
+// wasmContext.call0/callI32, which call this after every proxy_on_*
+// invocation.
+func (f *streamProxyWasmFilter) onCallCompleted(success bool) {
+	if f == nil || f.config == nil || f.config.PluginName == "" {
+		return
+	}
+
+	wrapper := mwasm.GetWasmManager().GetWasmPluginWrapperByName(f.config.PluginName)
+	if wrapper == nil {
+		return
+	}
+
+	if recorder, ok := wrapper.(invocationResultRecorder); ok {
+		recorder.RecordInvocationResult(success)
+	}
+}
@@ -0,0 +1,111 @@
+package proxywasm
+
+import (
+	"sync"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+	mwasm "mosn.io/mosn/pkg/wasm"
+)
+
+// MapType identifies which header/trailer map a proxy_get_header_map_value
+// call is reading, mirroring the proxy-wasm ABI's MapType enum. Only the four
+// maps this package actually tracks (on ABIContextWrapper) are supported
+// today; the gRPC and HTTP-call-response map types the full ABI defines are
+// not backed by any state in this series yet.
+type MapType int32
+
+const (
+	MapTypeHttpRequestHeaders MapType = iota
+	MapTypeHttpRequestTrailers
+	MapTypeHttpResponseHeaders
+	MapTypeHttpResponseTrailers
+)
+
+// hostCallHandlerSetter is implemented by every ProxyWasmExports
+// (proxyWasmExportsV01/V02, via the embedded *wasmContext) so
+// NewABIContextWrapper can make itself the HostCallHandler that wasmContext's
+// call0/callI32 mark active for the duration of each export call.
+type hostCallHandlerSetter interface {
+	setHostCallHandler(h mwasm.HostCallHandler)
+}
+
+// activeHandlers maps a types.WasmInstance to the HostCallHandler that
+// should service any proxy_* host call it makes right now. wasmContext.call0/
+// callI32 update the entry for their instance immediately before invoking an
+// export, which is safe because a single wasm instance never executes two
+// exports concurrently with itself.
+var activeHandlers sync.Map // types.WasmInstance -> mwasm.HostCallHandler
+
+func init() {
+	mwasm.SetHostCallResolver(func(instance mwasm.InstanceMemory) mwasm.HostCallHandler {
+		v, ok := activeHandlers.Load(instance)
+		if !ok {
+			return nil
+		}
+		handler, _ := v.(mwasm.HostCallHandler)
+		return handler
+	})
+}
+
+// markActive records that instance's next proxy_* host call (if the guest
+// makes one reentrantly while the export currently being called runs) should
+// be serviced by handler.
+func markActive(instance types.WasmInstance, handler mwasm.HostCallHandler) {
+	if handler == nil {
+		return
+	}
+	activeHandlers.Store(instance, handler)
+}
+
+// ProxyLog implements mwasm.HostCallHandler for proxy_log, forwarding the
+// guest's message to MOSN's own logger rather than tracking it separately.
+func (a *ABIContextWrapper) ProxyLog(level int32, message string) {
+	log.DefaultLogger.Infof("[proxywasm][guest][level=%d] %s", level, message)
+}
+
+// GetHeaderMapValue implements mwasm.HostCallHandler for
+// proxy_get_header_map_value, looking key up in the map named by mapType.
+func (a *ABIContextWrapper) GetHeaderMapValue(mapType int32, key string) (string, bool) {
+	var m map[string]string
+
+	switch MapType(mapType) {
+	case MapTypeHttpRequestHeaders:
+		m = a.RequestHeaders
+	case MapTypeHttpRequestTrailers:
+		m = a.RequestTrailers
+	case MapTypeHttpResponseHeaders:
+		m = a.ResponseHeaders
+	case MapTypeHttpResponseTrailers:
+		m = a.ResponseTrailers
+	default:
+		return "", false
+	}
+
+	v, ok := m[key]
+	return v, ok
+}
+
+// SendLocalResponse implements mwasm.HostCallHandler for
+// proxy_send_local_response.
+func (a *ABIContextWrapper) SendLocalResponse(statusCode int32, body string, headers map[string]string) {
+	a.LocalResponse = &LocalResponse{StatusCode: statusCode, Body: body, Headers: headers}
+}
+
+// SetEffectiveContext implements mwasm.HostCallHandler for
+// proxy_set_effective_context.
+func (a *ABIContextWrapper) SetEffectiveContext(contextId int32) {
+	a.mu.Lock()
+	a.effectiveContextId = contextId
+	a.mu.Unlock()
+}
+
+// EffectiveContextId returns the context id host calls are currently
+// attributed to: the context this wrapper was built for, unless
+// proxy_set_effective_context switched it.
+func (a *ABIContextWrapper) EffectiveContextId() int32 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.effectiveContextId
+}
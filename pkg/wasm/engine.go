@@ -0,0 +1,64 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package wasm
+
+import (
+	"sync"
+
+	"mosn.io/mosn/pkg/types"
+)
+
+// EngineWasmer and EngineWazero are the built-in engine names accepted by
+// v2.WasmVmConfig.Engine. An empty Engine defaults to EngineWasmer so existing
+// configs keep working unchanged.
+const (
+	EngineWasmer = "wasmer"
+	EngineWazero = "wazero"
+)
+
+var (
+	engineMu  sync.RWMutex
+	engineMap = make(map[string]types.WasmVM)
+)
+
+// RegisterWasmEngine registers a types.WasmVM implementation under name so it
+// becomes selectable via v2.WasmVmConfig.Engine. It is typically called from
+// the init() of the package providing the engine.
+func RegisterWasmEngine(name string, vm types.WasmVM) {
+	if name == "" || vm == nil {
+		return
+	}
+
+	engineMu.Lock()
+	engineMap[name] = vm
+	engineMu.Unlock()
+}
+
+// GetWasmEngine returns the registered engine for name, defaulting to the
+// wasmer engine when name is empty. It returns nil when no engine is
+// registered under name.
+func GetWasmEngine(name string) types.WasmVM {
+	if name == "" {
+		name = EngineWasmer
+	}
+
+	engineMu.RLock()
+	defer engineMu.RUnlock()
+
+	return engineMap[name]
+}
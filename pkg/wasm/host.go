@@ -0,0 +1,250 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package wasm
+
+import (
+	"errors"
+	"sync"
+)
+
+// WasmResult mirrors the proxy-wasm ABI's WasmResult enum: the status code
+// every proxy_* host call returns to the guest in addition to (or instead
+// of) any out-parameter it writes.
+type WasmResult int32
+
+const (
+	ResultOk WasmResult = iota
+	ResultNotFound
+	ResultBadArgument
+	ResultSerializationFailure
+	ResultParseFailure
+	ResultBadExpression
+	ResultInvalidMemoryAccess
+	ResultEmpty
+	ResultCasMismatch
+	ResultResultMismatch
+	ResultInternalFailure
+	ResultUnimplemented
+)
+
+// InstanceMemory is implemented by every engine's instance type
+// (pkg/wasm/wasmer, pkg/wasm/wazero), giving the host call bodies below raw
+// access to a module's linear memory and its own allocator export
+// (proxy_on_memory_allocate, the convention every proxy-wasm SDK exports for
+// exactly this purpose: the host cannot allocate guest memory itself).
+type InstanceMemory interface {
+	ReadMemory(offset, size int32) ([]byte, error)
+	WriteMemory(offset int32, data []byte) error
+	Malloc(size int32) (int32, error)
+}
+
+// HostCallHandler services the proxy_* host calls below against real
+// per-request state. pkg/wasm/wasmer and pkg/wasm/wazero know how to import
+// these calls and marshal their i32/memory arguments, but nothing about the
+// proxy-wasm ABI's actual semantics; pkg/filter/stream/proxy-wasm's
+// ABIContextWrapper is what implements this interface and is installed via
+// SetHostCallResolver.
+type HostCallHandler interface {
+	ProxyLog(level int32, message string)
+	GetHeaderMapValue(mapType int32, key string) (string, bool)
+	SendLocalResponse(statusCode int32, body string, headers map[string]string)
+	SetEffectiveContext(contextId int32)
+}
+
+// HostCallResolver returns the HostCallHandler that should service a proxy_*
+// host call currently in flight on instance (the InstanceMemory value the
+// call's own engine passed in, which is always the same value for the
+// lifetime of one wasm instance). Wasm execution on a single instance is
+// never concurrent with itself, so "the handler most recently made active on
+// this instance" is an unambiguous answer even though instances are pooled
+// and reused across many requests.
+type HostCallResolver func(instance InstanceMemory) HostCallHandler
+
+var (
+	hostCallResolverMu sync.RWMutex
+	hostCallResolver   HostCallResolver
+)
+
+// SetHostCallResolver installs resolver as the lookup every engine's
+// imported proxy_* host functions use to find the HostCallHandler to service
+// a call against. It is normally called once at startup by
+// pkg/filter/stream/proxy-wasm's init().
+func SetHostCallResolver(resolver HostCallResolver) {
+	hostCallResolverMu.Lock()
+	hostCallResolver = resolver
+	hostCallResolverMu.Unlock()
+}
+
+func resolveHostCallHandler(instance InstanceMemory) HostCallHandler {
+	hostCallResolverMu.RLock()
+	resolver := hostCallResolver
+	hostCallResolverMu.RUnlock()
+
+	if resolver == nil {
+		return nil
+	}
+	return resolver(instance)
+}
+
+// ProxyLog implements proxy_log(log_level, message_data, message_size): read
+// the guest's message out of its own memory and hand it to the active
+// HostCallHandler.
+func ProxyLog(mem InstanceMemory, logLevel, messageData, messageSize int32) int32 {
+	handler := resolveHostCallHandler(mem)
+	if handler == nil {
+		return int32(ResultInternalFailure)
+	}
+
+	message, err := mem.ReadMemory(messageData, messageSize)
+	if err != nil {
+		return int32(ResultInvalidMemoryAccess)
+	}
+
+	handler.ProxyLog(logLevel, string(message))
+	return int32(ResultOk)
+}
+
+// ProxyGetHeaderMapValue implements
+// proxy_get_header_map_value(map_type, key_data, key_size, return_value_data,
+// return_value_size): look key up in the header map named by mapType, then
+// hand the result back through a buffer allocated in the guest's own memory
+// (via its proxy_on_memory_allocate export) since the host cannot allocate
+// guest memory directly.
+func ProxyGetHeaderMapValue(mem InstanceMemory, mapType, keyData, keySize, returnValueData, returnValueSize int32) int32 {
+	handler := resolveHostCallHandler(mem)
+	if handler == nil {
+		return int32(ResultInternalFailure)
+	}
+
+	key, err := mem.ReadMemory(keyData, keySize)
+	if err != nil {
+		return int32(ResultInvalidMemoryAccess)
+	}
+
+	value, ok := handler.GetHeaderMapValue(mapType, string(key))
+	if !ok {
+		return int32(ResultNotFound)
+	}
+
+	ptr, err := mem.Malloc(int32(len(value)))
+	if err != nil {
+		return int32(ResultInternalFailure)
+	}
+	if err := mem.WriteMemory(ptr, []byte(value)); err != nil {
+		return int32(ResultInvalidMemoryAccess)
+	}
+	if err := mem.WriteMemory(returnValueData, encodeI32(ptr)); err != nil {
+		return int32(ResultInvalidMemoryAccess)
+	}
+	if err := mem.WriteMemory(returnValueSize, encodeI32(int32(len(value)))); err != nil {
+		return int32(ResultInvalidMemoryAccess)
+	}
+
+	return int32(ResultOk)
+}
+
+// ProxySendLocalResponse implements proxy_send_local_response(response_code,
+// response_code_details_data, response_code_details_size, body_data,
+// body_size, additional_headers_data, additional_headers_size, grpc_status).
+// response_code_details/grpc_status are accepted (to match the guest-facing
+// signature every SDK links against) but not forwarded: HostCallHandler's
+// SendLocalResponse only carries what pkg/wasm/test.Harness's LocalResponse
+// already records (status code, body, headers), which is what this series'
+// filter-side plumbing can actually act on today.
+func ProxySendLocalResponse(mem InstanceMemory, statusCode, detailsData, detailsSize, bodyData, bodySize, headersData, headersSize, grpcStatus int32) int32 {
+	handler := resolveHostCallHandler(mem)
+	if handler == nil {
+		return int32(ResultInternalFailure)
+	}
+
+	body, err := mem.ReadMemory(bodyData, bodySize)
+	if err != nil {
+		return int32(ResultInvalidMemoryAccess)
+	}
+
+	headers := map[string]string{}
+	if headersSize > 0 {
+		raw, err := mem.ReadMemory(headersData, headersSize)
+		if err != nil {
+			return int32(ResultInvalidMemoryAccess)
+		}
+		headers, err = decodeHeaderMap(raw)
+		if err != nil {
+			return int32(ResultBadArgument)
+		}
+	}
+
+	handler.SendLocalResponse(statusCode, string(body), headers)
+	return int32(ResultOk)
+}
+
+// ProxySetEffectiveContext implements proxy_set_effective_context(context_id).
+func ProxySetEffectiveContext(mem InstanceMemory, contextId int32) int32 {
+	handler := resolveHostCallHandler(mem)
+	if handler == nil {
+		return int32(ResultInternalFailure)
+	}
+
+	handler.SetEffectiveContext(contextId)
+	return int32(ResultOk)
+}
+
+// encodeI32 little-endian encodes v the way wasm32 linear memory expects,
+// for writing a host value into a guest out-parameter.
+func encodeI32(v int32) []byte {
+	return []byte{byte(v), byte(v >> 8), byte(v >> 16), byte(v >> 24)}
+}
+
+func decodeI32(b []byte) int32 {
+	return int32(uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24)
+}
+
+// decodeHeaderMap parses the proxy-wasm ABI's serialized header map: an i32
+// header count, followed by that many (key_size, value_size) i32 pairs,
+// followed by each header's NUL-terminated key and value bytes back to back.
+func decodeHeaderMap(data []byte) (map[string]string, error) {
+	if len(data) < 4 {
+		return nil, errors.New("wasm: header map too short")
+	}
+
+	count := int(decodeI32(data[0:4]))
+	sizesStart := 4
+	sizesLen := count * 8
+	if count < 0 || sizesStart+sizesLen > len(data) {
+		return nil, errors.New("wasm: header map size table truncated")
+	}
+
+	out := make(map[string]string, count)
+	pos := sizesStart + sizesLen
+	for i := 0; i < count; i++ {
+		keySize := int(decodeI32(data[sizesStart+i*8 : sizesStart+i*8+4]))
+		valSize := int(decodeI32(data[sizesStart+i*8+4 : sizesStart+i*8+8]))
+		if keySize < 0 || valSize < 0 || pos+keySize+1+valSize+1 > len(data) {
+			return nil, errors.New("wasm: header map entry data truncated")
+		}
+
+		key := string(data[pos : pos+keySize])
+		pos += keySize + 1 // skip the key's trailing NUL
+		value := string(data[pos : pos+valSize])
+		pos += valSize + 1 // skip the value's trailing NUL
+
+		out[key] = value
+	}
+
+	return out, nil
+}
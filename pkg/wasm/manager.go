@@ -19,12 +19,16 @@ package wasm
 
 import (
 	"errors"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sync"
+	"time"
 
 	"mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/wasm/store"
 )
 
 var (
@@ -33,6 +37,18 @@ var (
 	ErrPluginNotFound  = errors.New("wasm plugin not found")
 )
 
+// defaultCacheDir is where OCI-resolved wasm plugin bytes are cached,
+// overridable with SetOCICacheDir before the first AddOrUpdateWasm call with
+// a non-empty VmConfig.Url (the blobstore is created then, even for a plain
+// http(s) URL).
+var defaultCacheDir = filepath.Join(os.TempDir(), "mosn-wasm-cache")
+
+// SetOCICacheDir overrides the local directory wasmMangerImpl caches
+// OCI-pulled plugin bytes in.
+func SetOCICacheDir(dir string) {
+	defaultCacheDir = dir
+}
+
 var wasmManagerInstance types.WasmManager = &wasmMangerImpl{}
 
 func GetWasmManager() types.WasmManager {
@@ -44,6 +60,42 @@ type pluginWrapper struct {
 	plugin         types.WasmPlugin
 	config         v2.WasmPluginConfig
 	pluginHandlers []types.WasmPluginHandler
+
+	// strategy governs how Update swaps in a new plugin; see rollout.go.
+	// Immediate{} (the historical unconditional-swap behavior) is the
+	// default until SetRolloutStrategy is called.
+	strategy RolloutStrategy
+
+	// canaryPlugin/canaryPercent are populated by Canary.rollout while a
+	// canary is in flight; SelectForInvocation reads them to decide where to
+	// route a given call.
+	canaryPlugin  types.WasmPlugin
+	canaryPercent int
+
+	// stats tracks this plugin's live resource usage; see stats.go.
+	stats PluginStats
+}
+
+// SetRolloutStrategy overrides how subsequent calls to Update swap in a new
+// plugin version. Passing nil restores Immediate.
+func (w *pluginWrapper) SetRolloutStrategy(s RolloutStrategy) {
+	if s == nil {
+		s = Immediate{}
+	}
+
+	w.mu.Lock()
+	w.strategy = s
+	w.mu.Unlock()
+}
+
+func (w *pluginWrapper) rolloutStrategy() RolloutStrategy {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.strategy == nil {
+		return Immediate{}
+	}
+	return w.strategy
 }
 
 func (w *pluginWrapper) RegisterPluginHandler(pluginHandler types.WasmPluginHandler) {
@@ -98,23 +150,82 @@ func (w *pluginWrapper) Update(config v2.WasmPluginConfig, plugin types.WasmPlug
 		return
 	}
 
-	// do update plugin
-	for _, handler := range w.pluginHandlers {
-		handler.OnPluginStart(plugin)
+	// do update plugin, per the configured RolloutStrategy (Immediate unless
+	// SetRolloutStrategy was called) rather than always swapping right away.
+	w.rolloutStrategy().rollout(w, plugin)
+}
+
+type wasmMangerImpl struct {
+	pluginMap sync.Map
+
+	blobstoreOnce sync.Once
+	blobstore     *store.Blobstore
+
+	// descriptors tracks, per plugin name, the OCI descriptor its currently
+	// running module was resolved from, for Inspect.
+	descriptors sync.Map
+}
+
+func (w *wasmMangerImpl) getBlobstore() *store.Blobstore {
+	w.blobstoreOnce.Do(func() {
+		bs, err := store.NewBlobstore(defaultCacheDir)
+		if err != nil {
+			log.DefaultLogger.Errorf("[wasm][manager] fail to create oci blobstore at %v: %v", defaultCacheDir, err)
+			return
+		}
+		w.blobstore = bs
+	})
+	return w.blobstore
+}
+
+// resolveVmConfig rewrites config.VmConfig.Url into a local Path when it
+// names an OCI reference (oci://registry/repo:tag, or a bare
+// registry/repo@sha256:... reference), pulling it into the local blobstore
+// first if necessary. Configs whose Url is a plain path or http(s) URL are
+// returned unchanged.
+func (w *wasmMangerImpl) resolveVmConfig(config v2.WasmPluginConfig) (v2.WasmPluginConfig, error) {
+	if config.VmConfig == nil || config.VmConfig.Url == "" {
+		return config, nil
 	}
 
-	w.mu.Lock()
-	w.plugin = plugin
-	w.mu.Unlock()
+	bs := w.getBlobstore()
+	if bs == nil {
+		return config, nil
+	}
 
-	for _, handler := range w.pluginHandlers {
-		handler.OnPluginDestroy(oldPlugin)
+	path, desc, err := bs.Pull(config.VmConfig.Url, nil)
+	if err == store.ErrNotOCIReference {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
 	}
-	oldPlugin.Clear()
+
+	vmConfig := *config.VmConfig
+	vmConfig.Path = path
+	config.VmConfig = &vmConfig
+
+	w.descriptors.Store(config.PluginName, desc)
+
+	return config, nil
 }
 
-type wasmMangerImpl struct {
-	pluginMap sync.Map
+// SetVerifier installs v as the Verifier every subsequently fetched plugin
+// module is checked against before it is compiled. See Verifier for details.
+func (w *wasmMangerImpl) SetVerifier(v Verifier) {
+	SetVerifier(v)
+}
+
+// Inspect returns the OCI descriptor (digest, media type, pulled-at time)
+// the named plugin's module was last resolved from, so operators can audit
+// what is actually running. It returns false if the plugin was never
+// resolved from an OCI reference.
+func (w *wasmMangerImpl) Inspect(pluginName string) (store.Descriptor, bool) {
+	v, ok := w.descriptors.Load(pluginName)
+	if !ok {
+		return store.Descriptor{}, false
+	}
+	return v.(store.Descriptor), true
 }
 
 func (w *wasmMangerImpl) shouldCreateNewPlugin(newConfig v2.WasmPluginConfig, oldConfig v2.WasmPluginConfig) bool {
@@ -122,9 +233,7 @@ func (w *wasmMangerImpl) shouldCreateNewPlugin(newConfig v2.WasmPluginConfig, ol
 		return false
 	}
 
-	if newConfig.VmConfig.Engine != oldConfig.VmConfig.Engine ||
-		newConfig.VmConfig.Path != oldConfig.VmConfig.Path ||
-		newConfig.VmConfig.Url != oldConfig.VmConfig.Url {
+	if newConfig.VmConfig.Engine != oldConfig.VmConfig.Engine {
 		return true
 	}
 
@@ -133,6 +242,19 @@ func (w *wasmMangerImpl) shouldCreateNewPlugin(newConfig v2.WasmPluginConfig, ol
 	return false
 }
 
+// shouldHotReloadModule reports whether newConfig points at different wasm
+// bytes than oldConfig while keeping the same engine, in which case the
+// existing plugin can be kept and only its module/instances swapped via
+// wasmPluginImpl.UpdateModule instead of tearing the whole plugin down.
+func (w *wasmMangerImpl) shouldHotReloadModule(newConfig v2.WasmPluginConfig, oldConfig v2.WasmPluginConfig) bool {
+	if newConfig.VmConfig == nil || oldConfig.VmConfig == nil {
+		return false
+	}
+
+	return newConfig.VmConfig.Engine == oldConfig.VmConfig.Engine &&
+		(newConfig.VmConfig.Path != oldConfig.VmConfig.Path || newConfig.VmConfig.Url != oldConfig.VmConfig.Url)
+}
+
 func (w *wasmMangerImpl) updateWasm(pluginWrapper types.WasmPluginWrapper, newConfig v2.WasmPluginConfig) {
 	oldConfig := pluginWrapper.GetConfig()
 	if reflect.DeepEqual(newConfig, oldConfig) {
@@ -142,14 +264,35 @@ func (w *wasmMangerImpl) updateWasm(pluginWrapper types.WasmPluginWrapper, newCo
 
 	plugin := pluginWrapper.GetPlugin()
 
-	if w.shouldCreateNewPlugin(newConfig, pluginWrapper.GetConfig()) {
+	switch {
+	case w.shouldCreateNewPlugin(newConfig, oldConfig):
 		var err error
 		plugin, err = NewWasmPlugin(newConfig)
 		if err != nil {
 			log.DefaultLogger.Errorf("[wasm][manager] updateWasm fail to create wasm plugin: %v, err: %v", newConfig.PluginName, err)
 			return
 		}
-	} else {
+	case w.shouldHotReloadModule(newConfig, oldConfig):
+		reloadable, ok := plugin.(*wasmPluginImpl)
+		if !ok {
+			log.DefaultLogger.Errorf("[wasm][manager] updateWasm plugin does not support hot reload: %v", newConfig.PluginName)
+			return
+		}
+
+		fetchStart := time.Now()
+		var wasmBytes []byte
+		if newConfig.VmConfig.Path != "" {
+			wasmBytes = loadWasmBytesFromPath(newConfig.VmConfig.Path)
+		} else {
+			wasmBytes = loadWasmBytesFromUrl(newConfig.VmConfig.Url)
+		}
+		fetchLatency := time.Since(fetchStart)
+
+		if err := reloadable.UpdateModule(wasmBytes, fetchLatency); err != nil {
+			log.DefaultLogger.Errorf("[wasm][manager] updateWasm fail to hot reload module: %v, err: %v", newConfig.PluginName, err)
+			return
+		}
+	default:
 		actualNum := plugin.EnsureInstanceNum(newConfig.InstanceNum)
 		if actualNum == 0 {
 			log.DefaultLogger.Errorf("[wasm][manager] updateWasm fail to update wasm instance num, want num: %v, actual num: %v", newConfig.InstanceNum, actualNum)
@@ -162,6 +305,13 @@ func (w *wasmMangerImpl) updateWasm(pluginWrapper types.WasmPluginWrapper, newCo
 
 	pluginWrapper.Update(newConfig, plugin)
 
+	if pw, ok := pluginWrapper.(*pluginWrapper); ok {
+		if impl, ok := plugin.(*wasmPluginImpl); ok {
+			impl.SetStats(&pw.stats)
+		}
+		pw.stats.setInstanceCount(plugin.InstanceNum())
+	}
+
 	log.DefaultLogger.Infof("[wasm][manager] AddOrUpdateWasm update wasm plugin: %v, config: %v", newConfig.PluginName, newConfig)
 }
 
@@ -171,6 +321,13 @@ func (w *wasmMangerImpl) AddOrUpdateWasm(config v2.WasmPluginConfig) error {
 		return ErrEmptyPluginName
 	}
 
+	resolved, err := w.resolveVmConfig(config)
+	if err != nil {
+		log.DefaultLogger.Errorf("[wasm][manager] AddOrUpdateWasm fail to resolve oci reference: %v, err: %v", config.PluginName, err)
+		return err
+	}
+	config = resolved
+
 	if v, ok := w.pluginMap.Load(config.PluginName); ok {
 		pluginWrapper, ok := v.(*pluginWrapper)
 		if !ok {
@@ -190,7 +347,12 @@ func (w *wasmMangerImpl) AddOrUpdateWasm(config v2.WasmPluginConfig) error {
 		pw := &pluginWrapper{
 			plugin: plugin,
 			config: config,
+			stats:  PluginStats{PluginName: config.PluginName},
 		}
+		if impl, ok := plugin.(*wasmPluginImpl); ok {
+			impl.SetStats(&pw.stats)
+		}
+		pw.stats.setInstanceCount(plugin.InstanceNum())
 
 		w.pluginMap.LoadOrStore(config.PluginName, pw)
 
@@ -234,4 +396,4 @@ func (w *wasmMangerImpl) UninstallWasmPluginByName(pluginName string) error {
 	log.DefaultLogger.Infof("[wasm][manager] UninstallWasmPluginByName uninstall wasm plugin: %v", pluginName)
 
 	return nil
-}
\ No newline at end of file
+}
@@ -22,6 +22,7 @@ import (
 	"runtime"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	v2 "mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/log"
@@ -49,6 +50,66 @@ type wasmPluginImpl struct {
 	vm        types.WasmVM
 	wasmBytes []byte
 	module    types.WasmModule
+
+	// prevWasmBytes/prevModule retain the module this plugin ran before its
+	// most recent hot reload, so a caller can roll back to them if the new
+	// module fails to validate (see UpdateModule).
+	prevWasmBytes []byte
+	prevModule    types.WasmModule
+
+	// quota is nil unless SetQuota was called, in which case EnsureInstanceNum
+	// and TryGetInstance enforce it instead of letting the plugin scale or
+	// dispatch without bound.
+	quota *QuotaEnforcer
+
+	// stats is nil unless SetStats was called (wasmMangerImpl wires it to the
+	// owning pluginWrapper's PluginStats right after construction), in which
+	// case GetInstance/ReleaseInstance/UpdateModule record real invocation
+	// and reload activity into it instead of leaving it permanently zero.
+	stats *PluginStats
+
+	// acquireTimes tracks when each currently-checked-out instance wrapper
+	// was handed out by GetInstance, so ReleaseInstance can report the
+	// invocation's wall-clock duration to stats. Keyed by the wrapper's
+	// identity rather than threaded through the GetInstance/ReleaseInstance
+	// signatures, which callers outside this package already depend on.
+	acquireTimes sync.Map
+}
+
+// SetStats installs the PluginStats that GetInstance/ReleaseInstance/
+// UpdateModule record real activity into. Passing nil (the default) leaves
+// the plugin's activity unrecorded.
+func (w *wasmPluginImpl) SetStats(stats *PluginStats) {
+	w.lock.Lock()
+	w.stats = stats
+	w.lock.Unlock()
+}
+
+// SetQuota installs the resource limits EnsureInstanceNum and
+// TryGetInstance enforce from then on. Passing a zero-value QuotaLimits
+// (the default) leaves every dimension unlimited.
+func (w *wasmPluginImpl) SetQuota(limits QuotaLimits) {
+	w.lock.Lock()
+	w.quota = &QuotaEnforcer{Limits: limits}
+	w.lock.Unlock()
+}
+
+// TryGetInstance is GetInstance gated by the plugin's QuotaEnforcer: it
+// returns ErrQuotaExceeded instead of an instance once the configured
+// invocations/sec limit is exceeded, so a single overloaded plugin can't
+// starve the worker pool dispatching into it.
+func (w *wasmPluginImpl) TryGetInstance() (types.WasmInstanceWrapper, error) {
+	w.lock.RLock()
+	quota := w.quota
+	w.lock.RUnlock()
+
+	if quota != nil {
+		if err := quota.checkInvocationRate(time.Now().UnixNano()); err != nil {
+			return nil, err
+		}
+	}
+
+	return w.GetInstance(), nil
 }
 
 func NewWasmPlugin(wasmConfig v2.WasmPluginConfig) (types.WasmPlugin, error) {
@@ -79,6 +140,11 @@ func NewWasmPlugin(wasmConfig v2.WasmPluginConfig) (types.WasmPlugin, error) {
 		return nil, ErrWasmBytesLoad
 	}
 
+	if err := currentVerifier().Verify(pluginMetadataOf(wasmConfig, wasmBytes), wasmBytes); err != nil {
+		log.DefaultLogger.Errorf("[wasm][plugin] NewWasmPlugin plugin failed verification: %v, err: %v", wasmConfig.PluginName, err)
+		return nil, ErrVerificationFailed
+	}
+
 	// create wasm module
 	module := vm.NewModule(wasmBytes)
 	if module == nil {
@@ -106,6 +172,106 @@ func NewWasmPlugin(wasmConfig v2.WasmPluginConfig) (types.WasmPlugin, error) {
 	return plugin, nil
 }
 
+// UpdateModule hot-reloads the plugin's wasm module in place: it compiles
+// newWasmBytes against the plugin's existing engine, pre-warms a fresh pool
+// of w.instanceNum instances, and only then atomically swaps them in under
+// w.lock. The previous module and its instances are kept as prevModule/
+// draining until their occupancy (w.occupy) reaches zero, then discarded;
+// this is what lets Rollback below undo a reload whose new module turns out
+// to fail proxy_validate_configuration.
+//
+// Config-only changes (instance count, cpu/mem limits) should go through
+// EnsureInstanceNum/SetCpuLimit/SetMemLimit instead, which reuse the existing
+// module.
+//
+// fetchLatency is how long the caller spent fetching newWasmBytes before
+// calling UpdateModule (0 if it wasn't fetched, e.g. Rollback reusing
+// prevWasmBytes); it is recorded into stats alongside the verification time
+// spent here so PluginStats.FetchLatencyNanos/VerifyLatencyNanos reflect a
+// real reload instead of staying permanently zero.
+func (w *wasmPluginImpl) UpdateModule(newWasmBytes []byte, fetchLatency time.Duration) error {
+	if len(newWasmBytes) == 0 {
+		return ErrWasmBytesLoad
+	}
+
+	verifyStart := time.Now()
+	err := currentVerifier().Verify(pluginMetadataOf(w.config, newWasmBytes), newWasmBytes)
+	verifyLatency := time.Since(verifyStart)
+	if err != nil {
+		log.DefaultLogger.Errorf("[wasm][plugin] UpdateModule plugin failed verification: %v, err: %v", w.config.PluginName, err)
+		return ErrVerificationFailed
+	}
+
+	newModule := w.vm.NewModule(newWasmBytes)
+	if newModule == nil {
+		return ErrModuleCreate
+	}
+
+	newInstances := make([]types.WasmInstanceWrapper, 0, w.instanceNum)
+	for i := 0; i < w.instanceNum; i++ {
+		instance := newModule.NewInstance()
+		if instance == nil {
+			log.DefaultLogger.Errorf("[wasm][plugin] UpdateModule fail to pre-warm instance, i: %v", i)
+			continue
+		}
+		newInstances = append(newInstances, &wasmInstanceWrapperImpl{WasmInstance: instance})
+	}
+
+	if len(newInstances) == 0 {
+		return ErrInstanceCreate
+	}
+
+	w.lock.Lock()
+	oldWasmBytes, oldModule := w.wasmBytes, w.module
+	oldInstances := w.instanceWrappers
+
+	w.prevWasmBytes, w.prevModule = oldWasmBytes, oldModule
+	w.wasmBytes, w.module = newWasmBytes, newModule
+	w.instanceWrappers = newInstances
+	w.instanceNum = len(newInstances)
+	stats := w.stats
+	w.lock.Unlock()
+
+	if stats != nil {
+		stats.recordReload(fetchLatency, verifyLatency)
+	}
+
+	go w.drainInstances(oldInstances)
+
+	return nil
+}
+
+// Rollback restores the module/instances UpdateModule replaced, e.g. when the
+// caller determines the new module failed proxy_validate_configuration. It is
+// only valid to call once per UpdateModule and is a no-op if there is nothing
+// to roll back to.
+func (w *wasmPluginImpl) Rollback() error {
+	w.lock.RLock()
+	prevModule, prevWasmBytes := w.prevModule, w.prevWasmBytes
+	w.lock.RUnlock()
+
+	if prevModule == nil {
+		return nil
+	}
+
+	return w.UpdateModule(prevWasmBytes, 0)
+}
+
+// drainInstances waits for the plugin's occupancy to reach zero before
+// letting old go out of scope, so in-flight requests against them finish
+// cleanly instead of being cut off mid-call by the swap in UpdateModule.
+func (w *wasmPluginImpl) drainInstances(old []types.WasmInstanceWrapper) {
+	for atomic.LoadInt32(&w.occupy) > 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, iw := range old {
+		if iw != nil {
+			iw.Release()
+		}
+	}
+}
+
 // EnsureInstanceNum try to expand/shrink the num of instance to 'num'
 // and return the actual instance num
 func (w *wasmPluginImpl) EnsureInstanceNum(num int) int {
@@ -113,6 +279,17 @@ func (w *wasmPluginImpl) EnsureInstanceNum(num int) int {
 		return w.instanceNum
 	}
 
+	w.lock.RLock()
+	quota := w.quota
+	w.lock.RUnlock()
+
+	if quota != nil {
+		if err := quota.checkInstanceCount(num); err != nil {
+			log.DefaultLogger.Errorf("[wasm][plugin] EnsureInstanceNum quota exceeded, want: %v, limit: %v", num, quota.Limits.MaxInstances)
+			return w.instanceNum
+		}
+	}
+
 	if num < w.instanceNum {
 		w.lock.Lock()
 		for i := num; i < len(w.instanceWrappers); i++ {
@@ -197,11 +374,57 @@ func (w *wasmPluginImpl) GetInstance() types.WasmInstanceWrapper {
 	w.instanceWrappersIdx++
 	atomic.AddInt32(&w.occupy, 1)
 
+	stats := w.stats
+	if stats != nil {
+		stats.recordInvocationStart()
+		w.acquireTimes.Store(iw, time.Now())
+	}
+
 	return iw
 }
 
 func (w *wasmPluginImpl) ReleaseInstance(instanceWrapper types.WasmInstanceWrapper) {
 	atomic.AddInt32(&w.occupy, -1)
+
+	w.lock.RLock()
+	stats := w.stats
+	w.lock.RUnlock()
+
+	if stats == nil {
+		return
+	}
+
+	if acquiredAt, ok := w.acquireTimes.LoadAndDelete(instanceWrapper); ok {
+		stats.recordInvocationEnd(time.Since(acquiredAt.(time.Time)))
+	}
+}
+
+// ReportTrap removes the instance wrapper owning instance from the live pool
+// after a TrapError (see pkg/filter/stream/proxy-wasm's reportTrap) and
+// asynchronously rebuilds the pool back to its previous size via
+// EnsureInstanceNum, so a single misbehaving module instance cannot keep
+// breaking request handling. instance is matched against each pooled
+// wrapper's GetInstance() rather than taking the wrapper directly, since the
+// caller (the proxywasm filter) only ever sees the raw types.WasmInstance.
+func (w *wasmPluginImpl) ReportTrap(instance types.WasmInstance, trapErr error) {
+	log.DefaultLogger.Errorf("[wasm][plugin] instance trapped, removing it from the pool: %v", trapErr)
+
+	w.lock.Lock()
+	num := w.instanceNum
+	for i, iw := range w.instanceWrappers {
+		if iw.GetInstance() == instance {
+			w.instanceWrappers = append(w.instanceWrappers[:i], w.instanceWrappers[i+1:]...)
+			w.instanceNum = len(w.instanceWrappers)
+			break
+		}
+	}
+	w.lock.Unlock()
+
+	go func() {
+		if actual := w.EnsureInstanceNum(num); actual < num {
+			log.DefaultLogger.Errorf("[wasm][plugin] ReportTrap fail to fully replace trapped instance, want: %v, got: %v", num, actual)
+		}
+	}()
 }
 
 type DefaultWasmPluginHandler struct{}
@@ -0,0 +1,258 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package wasm
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+)
+
+// RolloutStrategy governs how pluginWrapper.Update swaps an old
+// types.WasmPlugin for a new one. The default, zero-value strategy is
+// Immediate, matching the unconditional swap this package has always done.
+type RolloutStrategy interface {
+	// rollout runs the strategy against w, swapping in newPlugin according
+	// to its own rules (immediately, gradually, or only after a warmup
+	// probe), and is responsible for calling the wrapper's
+	// OnPluginStart/OnPluginDestroy/OnPluginCanary* handlers as it goes.
+	rollout(w *pluginWrapper, newPlugin types.WasmPlugin)
+}
+
+// Immediate swaps in the new plugin unconditionally and destroys the old one
+// right away: the behavior pluginWrapper.Update always had.
+type Immediate struct{}
+
+func (Immediate) rollout(w *pluginWrapper, newPlugin types.WasmPlugin) {
+	w.swapPlugin(newPlugin)
+}
+
+// Canary keeps the old and new plugin live side by side, routing Percent of
+// invocations (checked via SelectForInvocation) to the new one. A
+// HealthChecker, if set, gates promotion to 100%/abort on failure; with no
+// HealthChecker the canary is promoted immediately after StepInterval.
+type Canary struct {
+	Percent      int
+	StepInterval time.Duration
+	Health       *HealthChecker
+}
+
+func (c Canary) rollout(w *pluginWrapper, newPlugin types.WasmPlugin) {
+	w.mu.Lock()
+	w.canaryPlugin = newPlugin
+	w.canaryPercent = c.Percent
+	w.mu.Unlock()
+
+	w.notify(func(h types.WasmPluginHandler) {
+		if ch, ok := h.(CanaryAwareWasmPluginHandler); ok {
+			ch.OnPluginCanaryStart(newPlugin)
+		}
+	})
+
+	go func() {
+		if c.StepInterval > 0 {
+			time.Sleep(c.StepInterval)
+		}
+
+		if c.Health != nil && !c.Health.Check(newPlugin) {
+			w.abortCanary(newPlugin)
+			return
+		}
+
+		w.promoteCanary(newPlugin)
+	}()
+}
+
+// BlueGreen instantiates the new plugin fully isolated from live traffic,
+// runs WarmupProbe against it, and only swaps it in on success; on failure
+// the old plugin is left running untouched (no partial/unconditional swap).
+type BlueGreen struct {
+	WarmupProbe func(types.WasmPlugin) error
+}
+
+func (b BlueGreen) rollout(w *pluginWrapper, newPlugin types.WasmPlugin) {
+	if b.WarmupProbe != nil {
+		if err := b.WarmupProbe(newPlugin); err != nil {
+			log.DefaultLogger.Errorf("[wasm][rollout] blue/green warmup probe failed, keeping old plugin: %v", err)
+			newPlugin.Clear()
+			return
+		}
+	}
+
+	w.swapPlugin(newPlugin)
+}
+
+// HealthChecker gates a Canary rollout's promotion: it runs Probe once as a
+// warmup check, then samples invocation outcomes reported via RecordResult
+// into a sliding window and trips once the error rate crosses Threshold.
+type HealthChecker struct {
+	Probe     func(types.WasmPlugin) error
+	Window    int
+	Threshold float64
+
+	mu      sync.Mutex
+	results []bool // true = success
+}
+
+// Check runs Probe (if set) and evaluates the sliding window collected so
+// far via RecordResult.
+func (h *HealthChecker) Check(plugin types.WasmPlugin) bool {
+	if h.Probe != nil {
+		if err := h.Probe(plugin); err != nil {
+			log.DefaultLogger.Errorf("[wasm][rollout] canary warmup probe failed: %v", err)
+			return false
+		}
+	}
+
+	h.mu.Lock()
+	results := append([]bool(nil), h.results...)
+	h.mu.Unlock()
+
+	if len(results) == 0 {
+		return true
+	}
+
+	failures := 0
+	for _, ok := range results {
+		if !ok {
+			failures++
+		}
+	}
+
+	errRate := float64(failures) / float64(len(results))
+	return errRate <= h.Threshold
+}
+
+// RecordResult feeds one invocation outcome into the sliding window,
+// trimming it to Window entries. Safe to call concurrently with Check and
+// with itself, since it runs on every live invocation's completion
+// (pluginWrapper.RecordInvocationResult) while Check may simultaneously be
+// evaluating the canary from its own goroutine (Canary.rollout).
+func (h *HealthChecker) RecordResult(success bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.results = append(h.results, success)
+	if h.Window > 0 && len(h.results) > h.Window {
+		h.results = h.results[len(h.results)-h.Window:]
+	}
+}
+
+// CanaryAwareWasmPluginHandler is implemented by handlers that want to
+// observe canary rollout transitions; plain types.WasmPluginHandler
+// implementations are unaffected and simply don't receive these callbacks.
+type CanaryAwareWasmPluginHandler interface {
+	types.WasmPluginHandler
+	OnPluginCanaryStart(newPlugin types.WasmPlugin)
+	OnPluginCanaryPromote(newPlugin types.WasmPlugin)
+	OnPluginCanaryAbort(newPlugin types.WasmPlugin)
+}
+
+// swapPlugin performs the old unconditional swap, preserved as the mechanism
+// Immediate (and a successful BlueGreen/Canary) swap through.
+func (w *pluginWrapper) swapPlugin(newPlugin types.WasmPlugin) {
+	w.notify(func(h types.WasmPluginHandler) { h.OnPluginStart(newPlugin) })
+
+	w.mu.Lock()
+	oldPlugin := w.plugin
+	w.plugin = newPlugin
+	w.canaryPlugin = nil
+	w.canaryPercent = 0
+	w.mu.Unlock()
+
+	w.notify(func(h types.WasmPluginHandler) { h.OnPluginDestroy(oldPlugin) })
+	if oldPlugin != nil {
+		oldPlugin.Clear()
+	}
+}
+
+func (w *pluginWrapper) promoteCanary(newPlugin types.WasmPlugin) {
+	w.notify(func(h types.WasmPluginHandler) {
+		if ch, ok := h.(CanaryAwareWasmPluginHandler); ok {
+			ch.OnPluginCanaryPromote(newPlugin)
+		}
+	})
+
+	w.swapPlugin(newPlugin)
+}
+
+func (w *pluginWrapper) abortCanary(newPlugin types.WasmPlugin) {
+	w.mu.Lock()
+	w.canaryPlugin = nil
+	w.canaryPercent = 0
+	w.mu.Unlock()
+
+	w.notify(func(h types.WasmPluginHandler) {
+		if ch, ok := h.(CanaryAwareWasmPluginHandler); ok {
+			ch.OnPluginCanaryAbort(newPlugin)
+		}
+	})
+
+	newPlugin.Clear()
+}
+
+// SelectForInvocation returns the plugin a single invocation should be
+// dispatched to: the canary plugin for roughly canaryPercent of calls while
+// a Canary rollout is in flight, the primary plugin otherwise.
+func (w *pluginWrapper) SelectForInvocation() types.WasmPlugin {
+	w.mu.RLock()
+	canary, percent, primary := w.canaryPlugin, w.canaryPercent, w.plugin
+	w.mu.RUnlock()
+
+	if canary == nil || percent <= 0 {
+		return primary
+	}
+	if percent >= 100 || rand.Intn(100) < percent {
+		return canary
+	}
+	return primary
+}
+
+// RecordInvocationResult feeds a single export call's outcome into w's
+// in-flight Canary rollout's HealthChecker, if any, so repeated real
+// failures against the canary can trip Check and trigger an automatic
+// abortCanary rather than the health window staying permanently unfed. It is
+// a no-op outside a Canary rollout, or when that Canary has no Health
+// checker configured. See pkg/filter/stream/proxy-wasm's wasmContext.call0/
+// callI32, which call this after every proxy_on_* invocation.
+func (w *pluginWrapper) RecordInvocationResult(success bool) {
+	w.mu.RLock()
+	strategy, canary := w.strategy, w.canaryPlugin
+	w.mu.RUnlock()
+
+	c, ok := strategy.(Canary)
+	if !ok || c.Health == nil || canary == nil {
+		return
+	}
+
+	c.Health.RecordResult(success)
+}
+
+// notify calls f with every registered handler under a read lock.
+func (w *pluginWrapper) notify(f func(types.WasmPluginHandler)) {
+	w.mu.RLock()
+	handlers := append([]types.WasmPluginHandler(nil), w.pluginHandlers...)
+	w.mu.RUnlock()
+
+	for _, h := range handlers {
+		f(h)
+	}
+}
@@ -0,0 +1,163 @@
+package wasm
+
+import (
+	"sync"
+	"testing"
+
+	"mosn.io/mosn/pkg/types"
+)
+
+// fakePlugin is a minimal types.WasmPlugin stand-in used only for identity
+// comparisons in these tests; embedding the interface satisfies every method
+// none of the exercised code paths actually call.
+type fakePlugin struct {
+	types.WasmPlugin
+	id string
+}
+
+func TestHealthCheckerCheckNoProbeNoHistory(t *testing.T) {
+	h := &HealthChecker{Threshold: 0.5}
+	if !h.Check(nil) {
+		t.Error("Check() with no probe and no recorded results = false, want true")
+	}
+}
+
+func TestHealthCheckerCheckProbeFailure(t *testing.T) {
+	h := &HealthChecker{Probe: func(types.WasmPlugin) error { return errFakeProbe }}
+	if h.Check(nil) {
+		t.Error("Check() with a failing probe = true, want false")
+	}
+}
+
+var errFakeProbe = &fakeErr{"probe failed"}
+
+type fakeErr struct{ msg string }
+
+func (e *fakeErr) Error() string { return e.msg }
+
+func TestHealthCheckerThreshold(t *testing.T) {
+	h := &HealthChecker{Threshold: 0.5, Window: 4}
+
+	h.RecordResult(true)
+	h.RecordResult(true)
+	if !h.Check(nil) {
+		t.Error("Check() with 0/2 failures and threshold 0.5 = false, want true")
+	}
+
+	h.RecordResult(false)
+	h.RecordResult(false)
+	h.RecordResult(false)
+	// Window=4 keeps only the last 4 results: true, false, false, false -> 75% error rate.
+	if h.Check(nil) {
+		t.Error("Check() with error rate above threshold = true, want false")
+	}
+}
+
+func TestHealthCheckerRecordResultTrimsToWindow(t *testing.T) {
+	h := &HealthChecker{Window: 2}
+	h.RecordResult(true)
+	h.RecordResult(true)
+	h.RecordResult(false)
+
+	if len(h.results) != 2 {
+		t.Fatalf("len(results) = %d, want 2 (trimmed to Window)", len(h.results))
+	}
+	if h.results[0] != true || h.results[1] != false {
+		t.Errorf("results = %v, want [true false] (oldest entry dropped)", h.results)
+	}
+}
+
+func TestSelectForInvocationNoCanary(t *testing.T) {
+	primary := &fakePlugin{id: "primary"}
+	w := &pluginWrapper{plugin: primary}
+
+	if got := w.SelectForInvocation(); got != types.WasmPlugin(primary) {
+		t.Error("SelectForInvocation() with no canary must return the primary plugin")
+	}
+}
+
+func TestSelectForInvocationPercentBounds(t *testing.T) {
+	primary := &fakePlugin{id: "primary"}
+	canary := &fakePlugin{id: "canary"}
+
+	zero := &pluginWrapper{plugin: primary, canaryPlugin: canary, canaryPercent: 0}
+	if got := zero.SelectForInvocation(); got != types.WasmPlugin(primary) {
+		t.Error("SelectForInvocation() with canaryPercent=0 must always return the primary plugin")
+	}
+
+	full := &pluginWrapper{plugin: primary, canaryPlugin: canary, canaryPercent: 100}
+	for i := 0; i < 20; i++ {
+		if got := full.SelectForInvocation(); got != types.WasmPlugin(canary) {
+			t.Error("SelectForInvocation() with canaryPercent=100 must always return the canary plugin")
+		}
+	}
+}
+
+func TestSelectForInvocationSplitsTraffic(t *testing.T) {
+	primary := &fakePlugin{id: "primary"}
+	canary := &fakePlugin{id: "canary"}
+	w := &pluginWrapper{plugin: primary, canaryPlugin: canary, canaryPercent: 50}
+
+	sawPrimary, sawCanary := false, false
+	for i := 0; i < 200 && !(sawPrimary && sawCanary); i++ {
+		switch w.SelectForInvocation() {
+		case types.WasmPlugin(primary):
+			sawPrimary = true
+		case types.WasmPlugin(canary):
+			sawCanary = true
+		}
+	}
+
+	if !sawPrimary || !sawCanary {
+		t.Errorf("SelectForInvocation() with canaryPercent=50 over 200 calls: sawPrimary=%v sawCanary=%v, want both true", sawPrimary, sawCanary)
+	}
+}
+
+// TestHealthCheckerConcurrentRecordAndCheck exercises RecordResult and Check
+// from many goroutines at once, mirroring real traffic feeding outcomes in
+// while Canary.rollout's own goroutine evaluates Check: run with -race to
+// catch a regression of the data race results was previously exposed to.
+func TestHealthCheckerConcurrentRecordAndCheck(t *testing.T) {
+	h := &HealthChecker{Threshold: 1, Window: 50}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			h.RecordResult(i%2 == 0)
+		}(i)
+		go func() {
+			defer wg.Done()
+			h.Check(nil)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRecordInvocationResultFeedsCanaryHealthChecker(t *testing.T) {
+	primary := &fakePlugin{id: "primary"}
+	canary := &fakePlugin{id: "canary"}
+	health := &HealthChecker{Threshold: 0.1, Window: 10}
+
+	w := &pluginWrapper{
+		plugin:        primary,
+		canaryPlugin:  canary,
+		canaryPercent: 100,
+		strategy:      Canary{Percent: 100, Health: health},
+	}
+
+	w.RecordInvocationResult(false)
+	w.RecordInvocationResult(false)
+
+	if health.Check(nil) {
+		t.Error("Check() after two recorded failures above threshold = true, want false")
+	}
+}
+
+func TestRecordInvocationResultNoopWithoutCanary(t *testing.T) {
+	w := &pluginWrapper{plugin: &fakePlugin{id: "primary"}}
+
+	// Must not panic with no strategy/Health configured at all.
+	w.RecordInvocationResult(false)
+}
@@ -0,0 +1,223 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package wasm
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrQuotaExceeded is returned by wasmPluginImpl.TryGetInstance when a
+// plugin's QuotaEnforcer trips on its invocations/sec limit, short-circuiting
+// the request instead of silently over-committing the plugin's resources.
+// EnsureInstanceNum does not return it: when MaxInstances would be exceeded
+// it logs the refusal and returns the unchanged instance count.
+var ErrQuotaExceeded = errors.New("wasm: plugin quota exceeded")
+
+// PluginStats tracks per-plugin resource usage, read by WasmManager.Stats()
+// and exported to Prometheus by pluginStatsCollector. All fields are updated
+// with atomic operations so they can be read concurrently with the hot path
+// that updates them.
+type PluginStats struct {
+	PluginName string
+
+	InstanceCount     int32
+	ActiveInvocations int32
+	TotalInvocations  int64
+
+	WallNanos int64
+
+	FetchLatencyNanos  int64
+	VerifyLatencyNanos int64
+	ReloadCount        int64
+}
+
+func (s *PluginStats) recordInvocationStart() {
+	atomic.AddInt32(&s.ActiveInvocations, 1)
+	atomic.AddInt64(&s.TotalInvocations, 1)
+}
+
+func (s *PluginStats) recordInvocationEnd(wallElapsed time.Duration) {
+	atomic.AddInt32(&s.ActiveInvocations, -1)
+	atomic.AddInt64(&s.WallNanos, wallElapsed.Nanoseconds())
+}
+
+func (s *PluginStats) recordReload(fetchLatency, verifyLatency time.Duration) {
+	atomic.AddInt64(&s.ReloadCount, 1)
+	atomic.AddInt64(&s.FetchLatencyNanos, fetchLatency.Nanoseconds())
+	atomic.AddInt64(&s.VerifyLatencyNanos, verifyLatency.Nanoseconds())
+}
+
+func (s *PluginStats) setInstanceCount(n int) {
+	atomic.StoreInt32(&s.InstanceCount, int32(n))
+}
+
+// snapshot returns a copy of s safe to hand to callers outside the package.
+func (s *PluginStats) snapshot() PluginStats {
+	return PluginStats{
+		PluginName:         s.PluginName,
+		InstanceCount:      atomic.LoadInt32(&s.InstanceCount),
+		ActiveInvocations:  atomic.LoadInt32(&s.ActiveInvocations),
+		TotalInvocations:   atomic.LoadInt64(&s.TotalInvocations),
+		WallNanos:          atomic.LoadInt64(&s.WallNanos),
+		FetchLatencyNanos:  atomic.LoadInt64(&s.FetchLatencyNanos),
+		VerifyLatencyNanos: atomic.LoadInt64(&s.VerifyLatencyNanos),
+		ReloadCount:        atomic.LoadInt64(&s.ReloadCount),
+	}
+}
+
+// QuotaLimits bounds a plugin's resource consumption. A zero value in any
+// field means "unlimited" for that dimension.
+type QuotaLimits struct {
+	MaxInstances         int
+	MaxInvocationsPerSec int
+}
+
+// QuotaEnforcer checks a plugin's requested instance count and invocation
+// rate against QuotaLimits, rather than letting the plugin silently
+// over-commit: TryGetInstance fails with ErrQuotaExceeded once the rate limit
+// is crossed, and EnsureInstanceNum keeps the current instance count instead
+// of scaling past MaxInstances.
+type QuotaEnforcer struct {
+	Limits QuotaLimits
+
+	invocationWindowStart int64 // unix nanos, set lazily
+	invocationsThisSecond int64
+}
+
+// checkInstanceCount returns ErrQuotaExceeded if requesting wantInstances
+// would exceed Limits.MaxInstances.
+func (q *QuotaEnforcer) checkInstanceCount(wantInstances int) error {
+	if q.Limits.MaxInstances > 0 && wantInstances > q.Limits.MaxInstances {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// checkInvocationRate returns ErrQuotaExceeded once more than
+// Limits.MaxInvocationsPerSec calls have been admitted within the current
+// one-second window, and resets the window once it elapses.
+func (q *QuotaEnforcer) checkInvocationRate(nowNanos int64) error {
+	if q.Limits.MaxInvocationsPerSec <= 0 {
+		return nil
+	}
+
+	windowStart := atomic.LoadInt64(&q.invocationWindowStart)
+	if nowNanos-windowStart >= time.Second.Nanoseconds() {
+		atomic.StoreInt64(&q.invocationWindowStart, nowNanos)
+		atomic.StoreInt64(&q.invocationsThisSecond, 0)
+	}
+
+	if atomic.AddInt64(&q.invocationsThisSecond, 1) > int64(q.Limits.MaxInvocationsPerSec) {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// Stats returns a snapshot of every registered plugin's PluginStats, keyed
+// by plugin name.
+func (w *wasmMangerImpl) Stats() map[string]PluginStats {
+	out := make(map[string]PluginStats)
+
+	w.pluginMap.Range(func(k, v interface{}) bool {
+		name, _ := k.(string)
+		pw, _ := v.(*pluginWrapper)
+		if pw == nil {
+			return true
+		}
+
+		out[name] = pw.stats.snapshot()
+		return true
+	})
+
+	return out
+}
+
+// AdminHandler serves the live PluginStats for every registered plugin as
+// JSON, mirroring the shape container plugin managers expose via
+// `docker plugin ls`/`inspect`. Register it on MOSN's admin HTTP mux to give
+// operators visibility into wasm plugins without scraping Prometheus.
+func AdminHandler(w http.ResponseWriter, r *http.Request) {
+	manager, ok := wasmManagerInstance.(*wasmMangerImpl)
+	if !ok {
+		http.Error(w, "wasm manager unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(manager.Stats())
+}
+
+const promNamespace = "mosn_wasm"
+
+// pluginStatsCollector implements prometheus.Collector over
+// wasmMangerImpl.Stats(), under the mosn_wasm_* namespace.
+type pluginStatsCollector struct {
+	manager *wasmMangerImpl
+
+	instanceCount     *prometheus.Desc
+	activeInvocations *prometheus.Desc
+	totalInvocations  *prometheus.Desc
+	wallSeconds       *prometheus.Desc
+	reloadCount       *prometheus.Desc
+}
+
+// NewPrometheusCollector builds a prometheus.Collector exposing
+// GetWasmManager()'s per-plugin PluginStats under the mosn_wasm_* namespace.
+// Register it with prometheus.MustRegister to wire it into MOSN's existing
+// /metrics endpoint.
+func NewPrometheusCollector() prometheus.Collector {
+	labels := []string{"plugin"}
+
+	return &pluginStatsCollector{
+		manager: wasmManagerInstance.(*wasmMangerImpl),
+		instanceCount: prometheus.NewDesc(
+			promNamespace+"_instance_count", "Live wasm instance count for a plugin.", labels, nil),
+		activeInvocations: prometheus.NewDesc(
+			promNamespace+"_active_invocations", "In-flight invocations for a plugin.", labels, nil),
+		totalInvocations: prometheus.NewDesc(
+			promNamespace+"_invocations_total", "Total invocations served by a plugin.", labels, nil),
+		wallSeconds: prometheus.NewDesc(
+			promNamespace+"_invocation_wall_seconds_total", "Cumulative wall-clock time spent executing a plugin's invocations.", labels, nil),
+		reloadCount: prometheus.NewDesc(
+			promNamespace+"_reload_count", "Number of hot reloads a plugin has gone through.", labels, nil),
+	}
+}
+
+func (c *pluginStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.instanceCount
+	ch <- c.activeInvocations
+	ch <- c.totalInvocations
+	ch <- c.wallSeconds
+	ch <- c.reloadCount
+}
+
+func (c *pluginStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	for name, s := range c.manager.Stats() {
+		ch <- prometheus.MustNewConstMetric(c.instanceCount, prometheus.GaugeValue, float64(s.InstanceCount), name)
+		ch <- prometheus.MustNewConstMetric(c.activeInvocations, prometheus.GaugeValue, float64(s.ActiveInvocations), name)
+		ch <- prometheus.MustNewConstMetric(c.totalInvocations, prometheus.CounterValue, float64(s.TotalInvocations), name)
+		ch <- prometheus.MustNewConstMetric(c.wallSeconds, prometheus.CounterValue, float64(s.WallNanos)/1e9, name)
+		ch <- prometheus.MustNewConstMetric(c.reloadCount, prometheus.CounterValue, float64(s.ReloadCount), name)
+	}
+}
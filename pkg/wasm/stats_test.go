@@ -0,0 +1,113 @@
+package wasm
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQuotaEnforcerCheckInstanceCount(t *testing.T) {
+	q := &QuotaEnforcer{Limits: QuotaLimits{MaxInstances: 3}}
+
+	if err := q.checkInstanceCount(3); err != nil {
+		t.Errorf("checkInstanceCount(3) = %v, want nil at the limit", err)
+	}
+	if err := q.checkInstanceCount(4); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("checkInstanceCount(4) = %v, want ErrQuotaExceeded", err)
+	}
+
+	unlimited := &QuotaEnforcer{}
+	if err := unlimited.checkInstanceCount(1_000_000); err != nil {
+		t.Errorf("checkInstanceCount() with MaxInstances=0 = %v, want nil (unlimited)", err)
+	}
+}
+
+func TestQuotaEnforcerCheckInvocationRate(t *testing.T) {
+	q := &QuotaEnforcer{Limits: QuotaLimits{MaxInvocationsPerSec: 2}}
+
+	start := int64(1_000_000_000) // arbitrary fixed base, this is pure math with no wall-clock dependency
+	if err := q.checkInvocationRate(start); err != nil {
+		t.Fatalf("1st call in window: %v, want nil", err)
+	}
+	if err := q.checkInvocationRate(start + 1); err != nil {
+		t.Fatalf("2nd call in window: %v, want nil", err)
+	}
+	if err := q.checkInvocationRate(start + 2); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("3rd call in window: %v, want ErrQuotaExceeded", err)
+	}
+
+	afterWindow := start + time.Second.Nanoseconds() + 1
+	if err := q.checkInvocationRate(afterWindow); err != nil {
+		t.Fatalf("1st call in next window: %v, want nil (window should have reset)", err)
+	}
+}
+
+func TestQuotaEnforcerCheckInvocationRateUnlimited(t *testing.T) {
+	q := &QuotaEnforcer{}
+	for i := 0; i < 100; i++ {
+		if err := q.checkInvocationRate(int64(i)); err != nil {
+			t.Fatalf("checkInvocationRate() with MaxInvocationsPerSec=0 = %v, want nil (unlimited)", err)
+		}
+	}
+}
+
+func TestPluginStatsRecordInvocation(t *testing.T) {
+	s := &PluginStats{PluginName: "p1"}
+
+	s.recordInvocationStart()
+	s.recordInvocationStart()
+	snap := s.snapshot()
+	if snap.ActiveInvocations != 2 || snap.TotalInvocations != 2 {
+		t.Errorf("after 2 starts: active=%d total=%d, want active=2 total=2", snap.ActiveInvocations, snap.TotalInvocations)
+	}
+
+	s.recordInvocationEnd(50 * time.Millisecond)
+	snap = s.snapshot()
+	if snap.ActiveInvocations != 1 {
+		t.Errorf("after 1 end: active=%d, want 1", snap.ActiveInvocations)
+	}
+	if snap.TotalInvocations != 2 {
+		t.Errorf("recordInvocationEnd must not change TotalInvocations, got %d", snap.TotalInvocations)
+	}
+	if snap.WallNanos != (50 * time.Millisecond).Nanoseconds() {
+		t.Errorf("WallNanos = %d, want %d", snap.WallNanos, (50 * time.Millisecond).Nanoseconds())
+	}
+}
+
+func TestPluginStatsRecordReload(t *testing.T) {
+	s := &PluginStats{}
+
+	s.recordReload(10*time.Millisecond, 5*time.Millisecond)
+	s.recordReload(20*time.Millisecond, 15*time.Millisecond)
+
+	snap := s.snapshot()
+	if snap.ReloadCount != 2 {
+		t.Errorf("ReloadCount = %d, want 2", snap.ReloadCount)
+	}
+	wantFetch := (30 * time.Millisecond).Nanoseconds()
+	wantVerify := (20 * time.Millisecond).Nanoseconds()
+	if snap.FetchLatencyNanos != wantFetch || snap.VerifyLatencyNanos != wantVerify {
+		t.Errorf("FetchLatencyNanos=%d VerifyLatencyNanos=%d, want %d/%d",
+			snap.FetchLatencyNanos, snap.VerifyLatencyNanos, wantFetch, wantVerify)
+	}
+}
+
+func TestPluginStatsSetInstanceCount(t *testing.T) {
+	s := &PluginStats{}
+	s.setInstanceCount(5)
+	if got := s.snapshot().InstanceCount; got != 5 {
+		t.Errorf("InstanceCount = %d, want 5", got)
+	}
+}
+
+func TestPluginStatsSnapshotIsACopy(t *testing.T) {
+	s := &PluginStats{PluginName: "p1"}
+	s.setInstanceCount(1)
+
+	snap := s.snapshot()
+	s.setInstanceCount(2)
+
+	if snap.InstanceCount != 1 {
+		t.Errorf("snapshot() returned a live view (InstanceCount=%d), want an independent copy frozen at 1", snap.InstanceCount)
+	}
+}
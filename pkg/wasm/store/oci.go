@@ -0,0 +1,329 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package store implements a content-addressable local cache for wasm
+// plugin bytes, keyed by sha256 digest, and a minimal OCI distribution
+// client that can populate it from an image reference. This lets
+// v2.WasmVmConfig.Url name an OCI reference (oci://registry/repo:tag, or a
+// bare registry/repo@sha256:... digest reference) in addition to a plain
+// file path or HTTP(S) URL.
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// WasmLayerMediaType is the OCI layer media type used for wasm module bytes,
+// as published by the Wasm OCI Artifacts spec.
+const WasmLayerMediaType = "application/vnd.wasm.content.layer.v1+wasm"
+
+var (
+	ErrNotOCIReference  = errors.New("wasm: not an oci reference")
+	ErrDigestMismatch   = errors.New("wasm: pulled blob digest does not match expected digest")
+	ErrNoWasmLayerFound = errors.New("wasm: image manifest has no layer with the wasm content media type")
+)
+
+// Descriptor is what WasmManager.Inspect returns for a plugin pulled through
+// the store: enough to audit what is actually running.
+type Descriptor struct {
+	Digest    string
+	MediaType string
+	Source    string
+	PulledAt  time.Time
+}
+
+// Blobstore is a content-addressable cache of wasm module bytes on the local
+// filesystem, rooted at Dir and keyed by "sha256:<hex>" digest.
+type Blobstore struct {
+	Dir string
+}
+
+// NewBlobstore returns a Blobstore rooted at dir, creating it if necessary.
+func NewBlobstore(dir string) (*Blobstore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Blobstore{Dir: dir}, nil
+}
+
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func (b *Blobstore) pathFor(digest string) string {
+	return filepath.Join(b.Dir, strings.ReplaceAll(digest, ":", "_"))
+}
+
+// Has reports whether digest is already cached locally.
+func (b *Blobstore) Has(digest string) bool {
+	_, err := os.Stat(b.pathFor(digest))
+	return err == nil
+}
+
+// Put verifies data hashes to digest and writes it into the blobstore,
+// returning the local path it was written to.
+func (b *Blobstore) Put(digest string, data []byte) (string, error) {
+	if actual := digestOf(data); actual != digest {
+		return "", fmt.Errorf("%w: want %s, got %s", ErrDigestMismatch, digest, actual)
+	}
+
+	path := b.pathFor(digest)
+	if err := ioutil.WriteFile(path, data, 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Path returns the local path digest would be (or is) stored at.
+func (b *Blobstore) Path(digest string) string {
+	return b.pathFor(digest)
+}
+
+// ParseReference splits a registry/repo:tag or registry/repo@sha256:...
+// reference, with or without an oci:// prefix, into its registry,
+// repository, and tag-or-digest reference. It returns ErrNotOCIReference for
+// http(s):// URLs, absolute file paths, and references with no registry host
+// or no tag/digest, so callers can fall back to their existing loader.
+func ParseReference(ref string) (registry, repository, reference string, err error) {
+	ref = strings.TrimPrefix(ref, "oci://")
+
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") || strings.HasPrefix(ref, "/") {
+		return "", "", "", ErrNotOCIReference
+	}
+
+	if !strings.Contains(ref, "@") && !strings.Contains(ref, ":") {
+		return "", "", "", ErrNotOCIReference
+	}
+
+	slash := strings.Index(ref, "/")
+	if slash < 0 {
+		return "", "", "", ErrNotOCIReference
+	}
+	registry = ref[:slash]
+	rest := ref[slash+1:]
+
+	if at := strings.Index(rest, "@"); at >= 0 {
+		return registry, rest[:at], rest[at+1:], nil
+	}
+	if c := strings.LastIndex(rest, ":"); c >= 0 {
+		return registry, rest[:c], rest[c+1:], nil
+	}
+
+	return "", "", "", ErrNotOCIReference
+}
+
+// parseBearerChallenge parses a WWW-Authenticate: Bearer realm="...",
+// service="...",scope="..." challenge header into its realm and the full set
+// of challenge parameters, as sent by essentially every OCI-compliant
+// registry (Docker Hub, GHCR, ECR, GCR, ...) on an unauthenticated request.
+func parseBearerChallenge(header string) (realm string, params map[string]string, ok bool) {
+	const prefix = "Bearer "
+	if !strings.HasPrefix(header, prefix) {
+		return "", nil, false
+	}
+
+	params = make(map[string]string)
+	for _, part := range strings.Split(header[len(prefix):], ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		params[kv[0]] = strings.Trim(kv[1], `"`)
+	}
+
+	realm = params["realm"]
+	if realm == "" {
+		return "", nil, false
+	}
+	return realm, params, true
+}
+
+// fetchBearerToken exchanges a registry's Bearer challenge for a token by
+// GETting its realm with the service/scope it named, the same anonymous
+// token flow docker/crane use against public images.
+func fetchBearerToken(client *http.Client, realm string, params map[string]string) (string, error) {
+	u, err := url.Parse(realm)
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	if service := params["service"]; service != "" {
+		q.Set("service", service)
+	}
+	if scope := params["scope"]; scope != "" {
+		q.Set("scope", scope)
+	}
+	u.RawQuery = q.Encode()
+
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("wasm: token endpoint %s: unexpected status %s", realm, resp.Status)
+	}
+
+	var tok struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", err
+	}
+
+	if tok.Token != "" {
+		return tok.Token, nil
+	}
+	return tok.AccessToken, nil
+}
+
+// doAuthenticated performs req and, if the registry challenges it with a 401
+// WWW-Authenticate: Bearer header, fetches the token the challenge names and
+// retries once with it attached. Almost every real-world registry requires
+// this even for anonymous pulls of public images, so Pull cannot talk to one
+// without it.
+func doAuthenticated(client *http.Client, req *http.Request) (*http.Response, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+
+	realm, params, ok := parseBearerChallenge(challenge)
+	if !ok {
+		return nil, fmt.Errorf("wasm: %s: unauthorized with no usable Bearer challenge", req.URL)
+	}
+
+	token, err := fetchBearerToken(client, realm, params)
+	if err != nil {
+		return nil, fmt.Errorf("wasm: fetching bearer token for %s: %w", req.URL, err)
+	}
+
+	retry := req.Clone(req.Context())
+	retry.Header.Set("Authorization", "Bearer "+token)
+	return client.Do(retry)
+}
+
+type manifest struct {
+	MediaType string `json:"mediaType"`
+	Layers    []struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+		Size      int64  `json:"size"`
+	} `json:"layers"`
+}
+
+// Pull resolves ref against its OCI registry using the standard distribution
+// protocol, downloads the first layer whose media type is
+// WasmLayerMediaType, verifies its digest, stores it in the blobstore, and
+// returns the local path plus the resolved descriptor.
+func (b *Blobstore) Pull(ref string, client *http.Client) (string, Descriptor, error) {
+	registry, repository, reference, err := ParseReference(ref)
+	if err != nil {
+		return "", Descriptor{}, err
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	manifestURL := fmt.Sprintf("https://%s/v2/%s/manifests/%s", registry, repository, reference)
+	req, err := http.NewRequest(http.MethodGet, manifestURL, nil)
+	if err != nil {
+		return "", Descriptor{}, err
+	}
+	req.Header.Set("Accept", "application/vnd.oci.image.manifest.v1+json")
+
+	resp, err := doAuthenticated(client, req)
+	if err != nil {
+		return "", Descriptor{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", Descriptor{}, fmt.Errorf("wasm: pull manifest for %s: unexpected status %s", ref, resp.Status)
+	}
+
+	var m manifest
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return "", Descriptor{}, err
+	}
+
+	var layerDigest string
+	for _, l := range m.Layers {
+		if l.MediaType == WasmLayerMediaType {
+			layerDigest = l.Digest
+			break
+		}
+	}
+	if layerDigest == "" {
+		return "", Descriptor{}, ErrNoWasmLayerFound
+	}
+
+	if b.Has(layerDigest) {
+		return b.Path(layerDigest), Descriptor{Digest: layerDigest, MediaType: WasmLayerMediaType, Source: ref, PulledAt: time.Now()}, nil
+	}
+
+	blobURL := fmt.Sprintf("https://%s/v2/%s/blobs/%s", registry, repository, layerDigest)
+	blobReq, err := http.NewRequest(http.MethodGet, blobURL, nil)
+	if err != nil {
+		return "", Descriptor{}, err
+	}
+
+	blobResp, err := doAuthenticated(client, blobReq)
+	if err != nil {
+		return "", Descriptor{}, err
+	}
+	defer blobResp.Body.Close()
+
+	if blobResp.StatusCode != http.StatusOK {
+		return "", Descriptor{}, fmt.Errorf("wasm: pull blob %s for %s: unexpected status %s", layerDigest, ref, blobResp.Status)
+	}
+
+	data, err := ioutil.ReadAll(blobResp.Body)
+	if err != nil {
+		return "", Descriptor{}, err
+	}
+
+	path, err := b.Put(layerDigest, data)
+	if err != nil {
+		return "", Descriptor{}, err
+	}
+
+	return path, Descriptor{Digest: layerDigest, MediaType: WasmLayerMediaType, Source: ref, PulledAt: time.Now()}, nil
+}
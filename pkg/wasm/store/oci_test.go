@@ -0,0 +1,249 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		name           string
+		ref            string
+		wantRegistry   string
+		wantRepository string
+		wantReference  string
+		wantErr        error
+	}{
+		{"oci scheme with tag", "oci://registry.example.com/wasm/filter:v1", "registry.example.com", "wasm/filter", "v1", nil},
+		{"bare reference with tag", "registry.example.com/wasm/filter:v1", "registry.example.com", "wasm/filter", "v1", nil},
+		{"digest reference", "registry.example.com/wasm/filter@sha256:abc", "registry.example.com", "wasm/filter", "sha256:abc", nil},
+		{"http url is not an oci reference", "http://example.com/plugin.wasm", "", "", "", ErrNotOCIReference},
+		{"https url is not an oci reference", "https://example.com/plugin.wasm", "", "", "", ErrNotOCIReference},
+		{"plain file path is not an oci reference", "/var/lib/plugin.wasm", "", "", "", ErrNotOCIReference},
+		{"no registry separator", "justarepo:v1", "", "", "", ErrNotOCIReference},
+		{"no tag or digest", "registry.example.com/wasm/filter", "", "", "", ErrNotOCIReference},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry, repository, reference, err := ParseReference(tt.ref)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseReference(%q) error = %v, want %v", tt.ref, err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if registry != tt.wantRegistry || repository != tt.wantRepository || reference != tt.wantReference {
+				t.Errorf("ParseReference(%q) = (%q, %q, %q), want (%q, %q, %q)",
+					tt.ref, registry, repository, reference, tt.wantRegistry, tt.wantRepository, tt.wantReference)
+			}
+		})
+	}
+}
+
+func TestBlobstorePutHasPath(t *testing.T) {
+	b, err := NewBlobstore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewBlobstore: %v", err)
+	}
+
+	data := []byte("wasm module bytes")
+	digest := digestOf(data)
+
+	if b.Has(digest) {
+		t.Fatal("Has() = true before Put")
+	}
+
+	path, err := b.Put(digest, data)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if path != b.Path(digest) {
+		t.Errorf("Put() path = %q, want %q", path, b.Path(digest))
+	}
+	if !b.Has(digest) {
+		t.Error("Has() = false after Put")
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(got) != string(data) {
+		t.Errorf("stored bytes = %q, want %q", got, data)
+	}
+}
+
+func TestBlobstorePutDigestMismatch(t *testing.T) {
+	b, err := NewBlobstore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewBlobstore: %v", err)
+	}
+
+	if _, err := b.Put("sha256:wrongdigest", []byte("data")); !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("Put() error = %v, want ErrDigestMismatch", err)
+	}
+}
+
+func TestBlobstorePathIsStableAcrossDirs(t *testing.T) {
+	dir := t.TempDir()
+	b := &Blobstore{Dir: dir}
+
+	got := b.Path("sha256:abc123")
+	want := filepath.Join(dir, "sha256_abc123")
+	if got != want {
+		t.Errorf("Path() = %q, want %q", got, want)
+	}
+}
+
+func TestParseBearerChallenge(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     string
+		wantOK     bool
+		wantRealm  string
+		wantParams map[string]string
+	}{
+		{
+			name:      "well formed challenge",
+			header:    `Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:wasm/filter:pull"`,
+			wantOK:    true,
+			wantRealm: "https://auth.example.com/token",
+			wantParams: map[string]string{
+				"realm":   "https://auth.example.com/token",
+				"service": "registry.example.com",
+				"scope":   "repository:wasm/filter:pull",
+			},
+		},
+		{"not a bearer challenge", `Basic realm="registry"`, false, "", nil},
+		{"bearer with no realm", `Bearer service="registry.example.com"`, false, "", nil},
+		{"empty header", "", false, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			realm, params, ok := parseBearerChallenge(tt.header)
+			if ok != tt.wantOK {
+				t.Fatalf("parseBearerChallenge() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if realm != tt.wantRealm {
+				t.Errorf("realm = %q, want %q", realm, tt.wantRealm)
+			}
+			for k, v := range tt.wantParams {
+				if params[k] != v {
+					t.Errorf("params[%q] = %q, want %q", k, params[k], v)
+				}
+			}
+		})
+	}
+}
+
+// fakeRegistry serves a minimal OCI distribution API: an anonymous
+// Bearer-token challenge on the first unauthenticated request, then a
+// manifest and a single wasm layer blob once a token is presented.
+func fakeRegistry(t *testing.T, blob []byte) *httptest.Server {
+	t.Helper()
+
+	layerDigest := digestOf(blob)
+	const token = "test-token"
+
+	mux := http.NewServeMux()
+	var server *httptest.Server
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": token})
+	})
+
+	requireAuth := func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") == "Bearer "+token {
+			return true
+		}
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="fake"`, server.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+
+	mux.HandleFunc("/v2/wasm/filter/manifests/v1", func(w http.ResponseWriter, r *http.Request) {
+		if !requireAuth(w, r) {
+			return
+		}
+		_ = json.NewEncoder(w).Encode(manifest{
+			MediaType: "application/vnd.oci.image.manifest.v1+json",
+			Layers: []struct {
+				MediaType string `json:"mediaType"`
+				Digest    string `json:"digest"`
+				Size      int64  `json:"size"`
+			}{{MediaType: WasmLayerMediaType, Digest: layerDigest, Size: int64(len(blob))}},
+		})
+	})
+
+	mux.HandleFunc("/v2/wasm/filter/blobs/"+layerDigest, func(w http.ResponseWriter, r *http.Request) {
+		if !requireAuth(w, r) {
+			return
+		}
+		_, _ = w.Write(blob)
+	})
+
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestBlobstorePullHandlesBearerChallenge(t *testing.T) {
+	blob := []byte("wasm module bytes")
+	server := fakeRegistry(t, blob)
+	registryHost := server.Listener.Addr().String()
+
+	b, err := NewBlobstore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewBlobstore: %v", err)
+	}
+
+	// Pull always talks https://<registry>/..., so point it at our plain-http
+	// test server by overriding the scheme via a Transport that redirects.
+	client := &http.Client{Transport: redirectToServer{server: server}}
+
+	ref := registryHost + "/wasm/filter:v1"
+	path, desc, err := b.Pull(ref, client)
+	if err != nil {
+		t.Fatalf("Pull(%q): %v", ref, err)
+	}
+
+	if desc.Digest != digestOf(blob) {
+		t.Errorf("Descriptor.Digest = %q, want %q", desc.Digest, digestOf(blob))
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile(%q): %v", path, err)
+	}
+	if string(got) != string(blob) {
+		t.Errorf("pulled bytes = %q, want %q", got, blob)
+	}
+	if !b.Has(desc.Digest) {
+		t.Error("Has() = false after a successful Pull")
+	}
+}
+
+// redirectToServer rewrites every request's scheme/host to point at the
+// given httptest.Server, so Pull's hardcoded "https://<registry>/..." URLs
+// can be exercised against a plain-http test server without changing Pull.
+type redirectToServer struct {
+	server *httptest.Server
+}
+
+func (r redirectToServer) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = "http"
+	req.URL.Host = r.server.Listener.Addr().String()
+	req.Host = req.URL.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
@@ -0,0 +1,349 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package test provides a fake proxy-wasm host for exercising compiled .wasm
+// modules inside unit tests, without a real MOSN listener, filter chain, or
+// network connection. It drives a module's standard lifecycle
+// (_start -> proxy_on_vm_start -> proxy_on_context_create -> proxy_on_*),
+// implements mwasm.HostCallHandler so a module's proxy_log/
+// proxy_get_header_map_value/proxy_send_local_response/
+// proxy_set_effective_context calls are serviced against the harness's own
+// state, and records every such host-side effect so tests can assert on them.
+// HTTP-call scripting (ScriptHTTPCall) and the shared-data/queue store are
+// plain Go-level state a test can read and write directly; dispatching them
+// automatically in response to a module's proxy_dispatch_http_call/
+// proxy_get_shared_data/proxy_register_shared_queue calls is not implemented
+// (those host calls aren't part of mwasm.HostCallHandler yet).
+package test
+
+import (
+	"io/ioutil"
+	"sync"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+	mwasm "mosn.io/mosn/pkg/wasm"
+)
+
+// mapType mirrors the proxy-wasm ABI's MapType enum for the four maps a
+// Harness tracks, in the same order pkg/filter/stream/proxy-wasm's MapType
+// uses (see that package's host_calls.go) since both are fixed by the wire
+// protocol, not by either package.
+type mapType int32
+
+const (
+	mapTypeHttpRequestHeaders mapType = iota
+	mapTypeHttpRequestTrailers
+	mapTypeHttpResponseHeaders
+	mapTypeHttpResponseTrailers
+)
+
+// activeHarnesses maps a loaded instance to the Harness that should service
+// its proxy_* host calls, mirroring pkg/filter/stream/proxy-wasm's
+// activeHandlers: markActive is called immediately before every Call, which
+// is safe because a single wasm instance never executes two exports
+// concurrently with itself.
+var activeHarnesses sync.Map // types.WasmInstance -> *Harness
+
+func init() {
+	mwasm.SetHostCallResolver(func(instance mwasm.InstanceMemory) mwasm.HostCallHandler {
+		v, ok := activeHarnesses.Load(instance)
+		if !ok {
+			return nil
+		}
+		h, _ := v.(*Harness)
+		return h
+	})
+}
+
+// RecordedLog is a single log line a module emitted through proxy_log.
+type RecordedLog struct {
+	Level   int32
+	Message string
+}
+
+// RecordedMetric is a single metric mutation recorded via RecordMetric. A
+// module's proxy_{define,increment,record}_metric calls are not routed here,
+// since those host calls are not part of mwasm.HostCallHandler.
+type RecordedMetric struct {
+	Name  string
+	Value int64
+}
+
+// HTTPCallScript lets a test script the response to a pending
+// proxy_dispatch_http_call, keyed by the upstream cluster name the module
+// requested.
+type HTTPCallScript struct {
+	Headers  map[string]string
+	Body     []byte
+	Trailers map[string]string
+}
+
+// Harness is a fake proxy-wasm host: canned request/response state, a table
+// of scripted HTTP-call responses, a shared-data/queue store, a virtual
+// clock, and sinks recording every host call a module makes via
+// mwasm.HostCallHandler. The virtual clock does not call the module's
+// proxy_on_tick export (see AdvanceClock).
+type Harness struct {
+	Engine string
+
+	RequestHeaders   map[string]string
+	RequestBody      []byte
+	RequestTrailers  map[string]string
+	ResponseHeaders  map[string]string
+	ResponseBody     []byte
+	ResponseTrailers map[string]string
+
+	// LocalResponseSent records the last call to proxy_send_local_response,
+	// nil until one is made.
+	LocalResponseSent *LocalResponse
+
+	// effectiveContextId is the contextId proxy_set_effective_context last
+	// switched host calls to.
+	effectiveContextId int32
+
+	httpCallScripts map[string]HTTPCallScript
+	sharedData      map[string][]byte
+	sharedQueues    map[string][][]byte
+
+	clock *virtualClock
+
+	Logs    []RecordedLog
+	Metrics []RecordedMetric
+
+	module   types.WasmModule
+	Instance types.WasmInstance
+}
+
+// LocalResponse is what proxy_send_local_response was called with.
+type LocalResponse struct {
+	StatusCode int32
+	Body       string
+	Headers    map[string]string
+}
+
+// NewHarness builds an empty harness for the named engine ("wasmer" or
+// "wazero"); see pkg/wasm.GetWasmEngine for the set of registered engines.
+func NewHarness(engine string) *Harness {
+	return &Harness{
+		Engine:           engine,
+		RequestHeaders:   map[string]string{},
+		RequestTrailers:  map[string]string{},
+		ResponseHeaders:  map[string]string{},
+		ResponseTrailers: map[string]string{},
+		httpCallScripts:  map[string]HTTPCallScript{},
+		sharedData:       map[string][]byte{},
+		sharedQueues:     map[string][][]byte{},
+		clock:            newVirtualClock(),
+	}
+}
+
+// ScriptHTTPCall registers resp as the scripted response for cluster,
+// readable back via HTTPCallScript. The harness does not hand it to the
+// module on its own: a module's proxy_dispatch_http_call is not serviced.
+func (h *Harness) ScriptHTTPCall(cluster string, resp HTTPCallScript) {
+	h.httpCallScripts[cluster] = resp
+}
+
+// HTTPCallScript returns the response scripted for cluster via ScriptHTTPCall,
+// if any.
+func (h *Harness) HTTPCallScript(cluster string) (HTTPCallScript, bool) {
+	resp, ok := h.httpCallScripts[cluster]
+	return resp, ok
+}
+
+// SetSharedData stores data under key, as if the module had called
+// proxy_set_shared_data.
+func (h *Harness) SetSharedData(key string, data []byte) {
+	h.sharedData[key] = data
+}
+
+// SharedData returns the data last stored under key via SetSharedData, as if
+// the module had called proxy_get_shared_data.
+func (h *Harness) SharedData(key string) ([]byte, bool) {
+	data, ok := h.sharedData[key]
+	return data, ok
+}
+
+// EnqueueSharedQueue appends data to the named shared queue, as if the
+// module had called proxy_enqueue_shared_queue.
+func (h *Harness) EnqueueSharedQueue(queue string, data []byte) {
+	h.sharedQueues[queue] = append(h.sharedQueues[queue], data)
+}
+
+// DequeueSharedQueue pops and returns the oldest entry enqueued on queue, as
+// if the module had called proxy_dequeue_shared_queue.
+func (h *Harness) DequeueSharedQueue(queue string) ([]byte, bool) {
+	entries := h.sharedQueues[queue]
+	if len(entries) == 0 {
+		return nil, false
+	}
+
+	data := entries[0]
+	h.sharedQueues[queue] = entries[1:]
+	return data, true
+}
+
+// AdvanceClock moves the virtual clock forward by millis milliseconds,
+// running the clock's tick callback once for every tick period crossed. No
+// tick period or callback is installed by NewHarness or LoadModule, so this
+// does not call proxy_on_tick on the loaded instance.
+func (h *Harness) AdvanceClock(millis int32) {
+	h.clock.advance(millis)
+}
+
+// RecordLog appends a log line, as if the module had called proxy_log.
+func (h *Harness) RecordLog(level int32, message string) {
+	h.Logs = append(h.Logs, RecordedLog{Level: level, Message: message})
+}
+
+// RecordMetric appends a metric mutation, as if the module had called
+// proxy_record_metric / proxy_increment_metric.
+func (h *Harness) RecordMetric(name string, value int64) {
+	h.Metrics = append(h.Metrics, RecordedMetric{Name: name, Value: value})
+}
+
+// LoadModule compiles path with the harness's engine, ready for Run. Tests
+// that only need to assert host-call recording without executing real wasm
+// bytes can skip this and drive Harness's recorders directly.
+func (h *Harness) LoadModule(path string) error {
+	wasmBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	vm := mwasm.GetWasmEngine(h.Engine)
+	if vm == nil {
+		return mwasm.ErrEngineNotFound
+	}
+
+	module := vm.NewModule(wasmBytes)
+	if module == nil {
+		log.DefaultLogger.Errorf("[wasm][test] LoadModule fail to compile module: %v", path)
+		return mwasm.ErrModuleCreate
+	}
+
+	instance := module.NewInstance()
+	if instance == nil {
+		return mwasm.ErrInstanceCreate
+	}
+
+	h.module = module
+	h.Instance = instance
+	activeHarnesses.Store(instance, h)
+	return nil
+}
+
+// Call invokes a proxy_on_* export on the loaded instance, e.g.
+// h.Call("proxy_on_vm_start", 1, 0). Any proxy_log/proxy_get_header_map_value/
+// proxy_send_local_response/proxy_set_effective_context call the export makes
+// is serviced by h itself (see ProxyLog et al.), matching how
+// pkg/filter/stream/proxy-wasm marks its own handler active before a call.
+func (h *Harness) Call(export string, args ...int32) (int32, error) {
+	activeHarnesses.Store(h.Instance, h)
+
+	fn, err := h.Instance.GetExportsFunc(export)
+	if err != nil {
+		return 0, err
+	}
+	return fn.Call(args...)
+}
+
+// ProxyLog implements mwasm.HostCallHandler for proxy_log.
+func (h *Harness) ProxyLog(level int32, message string) {
+	h.RecordLog(level, message)
+}
+
+// GetHeaderMapValue implements mwasm.HostCallHandler for
+// proxy_get_header_map_value, looking key up in the map named by mapType.
+func (h *Harness) GetHeaderMapValue(mt int32, key string) (string, bool) {
+	var m map[string]string
+
+	switch mapType(mt) {
+	case mapTypeHttpRequestHeaders:
+		m = h.RequestHeaders
+	case mapTypeHttpRequestTrailers:
+		m = h.RequestTrailers
+	case mapTypeHttpResponseHeaders:
+		m = h.ResponseHeaders
+	case mapTypeHttpResponseTrailers:
+		m = h.ResponseTrailers
+	default:
+		return "", false
+	}
+
+	v, ok := m[key]
+	return v, ok
+}
+
+// SendLocalResponse implements mwasm.HostCallHandler for
+// proxy_send_local_response.
+func (h *Harness) SendLocalResponse(statusCode int32, body string, headers map[string]string) {
+	h.LocalResponseSent = &LocalResponse{StatusCode: statusCode, Body: body, Headers: headers}
+}
+
+// SetEffectiveContext implements mwasm.HostCallHandler for
+// proxy_set_effective_context.
+func (h *Harness) SetEffectiveContext(contextId int32) {
+	h.effectiveContextId = contextId
+}
+
+// EffectiveContextId returns the context id host calls are currently
+// attributed to, as last set by proxy_set_effective_context (0 if never
+// called).
+func (h *Harness) EffectiveContextId() int32 {
+	return h.effectiveContextId
+}
+
+// virtualClock is a deterministic stand-in for wall-clock time, so tests
+// exercising proxy_on_tick don't depend on real sleeps.
+type virtualClock struct {
+	nowMillis   int64
+	tickPeriod  int64
+	onTick      func()
+	accumulated int64
+}
+
+func newVirtualClock() *virtualClock {
+	return &virtualClock{}
+}
+
+// SetTickPeriod configures the period (in milliseconds) at which onTick
+// fires as the clock is advanced, mirroring proxy_set_tick_period_milliseconds.
+func (c *virtualClock) SetTickPeriod(periodMillis int64, onTick func()) {
+	c.tickPeriod = periodMillis
+	c.onTick = onTick
+}
+
+func (c *virtualClock) advance(millis int32) {
+	c.nowMillis += int64(millis)
+	if c.tickPeriod <= 0 || c.onTick == nil {
+		return
+	}
+
+	c.accumulated += int64(millis)
+	for c.accumulated >= c.tickPeriod {
+		c.accumulated -= c.tickPeriod
+		c.onTick()
+	}
+}
+
+// Now returns the current virtual time in milliseconds since the harness was
+// created, mirroring proxy_get_current_time_nanoseconds.
+func (c *virtualClock) Now() int64 {
+	return c.nowMillis
+}
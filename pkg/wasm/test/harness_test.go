@@ -0,0 +1,161 @@
+package test
+
+import "testing"
+
+func TestNewHarnessInitializesMaps(t *testing.T) {
+	h := NewHarness("wazero")
+
+	if h.Engine != "wazero" {
+		t.Errorf("Engine = %q, want %q", h.Engine, "wazero")
+	}
+	if h.RequestHeaders == nil || h.ResponseHeaders == nil || h.RequestTrailers == nil || h.ResponseTrailers == nil {
+		t.Error("NewHarness must pre-initialize header/trailer maps so tests can assign into them directly")
+	}
+	if h.LocalResponseSent != nil {
+		t.Error("LocalResponseSent must be nil until proxy_send_local_response is simulated")
+	}
+}
+
+func TestHarnessRecordLogAndMetric(t *testing.T) {
+	h := NewHarness("wasmer")
+
+	h.RecordLog(2, "hello")
+	h.RecordMetric("requests_total", 1)
+	h.RecordMetric("requests_total", 2)
+
+	if len(h.Logs) != 1 || h.Logs[0] != (RecordedLog{Level: 2, Message: "hello"}) {
+		t.Errorf("Logs = %v, want a single {2 hello} entry", h.Logs)
+	}
+	if len(h.Metrics) != 2 {
+		t.Fatalf("len(Metrics) = %d, want 2", len(h.Metrics))
+	}
+	if h.Metrics[1] != (RecordedMetric{Name: "requests_total", Value: 2}) {
+		t.Errorf("Metrics[1] = %v, want {requests_total 2}", h.Metrics[1])
+	}
+}
+
+func TestHarnessScriptHTTPCall(t *testing.T) {
+	h := NewHarness("wasmer")
+
+	h.ScriptHTTPCall("upstream-cluster", HTTPCallScript{
+		Headers: map[string]string{":status": "200"},
+		Body:    []byte("ok"),
+	})
+
+	script, ok := h.httpCallScripts["upstream-cluster"]
+	if !ok {
+		t.Fatal("ScriptHTTPCall did not register a script for the given cluster")
+	}
+	if string(script.Body) != "ok" {
+		t.Errorf("scripted body = %q, want %q", script.Body, "ok")
+	}
+}
+
+func TestVirtualClockAdvanceWithoutTickPeriod(t *testing.T) {
+	c := newVirtualClock()
+	c.advance(500)
+
+	if c.Now() != 500 {
+		t.Errorf("Now() = %d, want 500", c.Now())
+	}
+}
+
+func TestVirtualClockFiresTicksAtPeriod(t *testing.T) {
+	c := newVirtualClock()
+
+	ticks := 0
+	c.SetTickPeriod(100, func() { ticks++ })
+
+	c.advance(250)
+	if ticks != 2 {
+		t.Errorf("ticks after advancing 250ms at a 100ms period = %d, want 2", ticks)
+	}
+
+	c.advance(40)
+	if ticks != 2 {
+		t.Errorf("ticks after advancing another 40ms (290ms in total) = %d, want still 2", ticks)
+	}
+
+	c.advance(50)
+	if ticks != 3 {
+		t.Errorf("ticks after crossing the 3rd period boundary = %d, want 3", ticks)
+	}
+}
+
+func TestHarnessGetHeaderMapValue(t *testing.T) {
+	h := NewHarness("wasmer")
+	h.RequestHeaders[":path"] = "/foo"
+	h.ResponseHeaders["content-type"] = "text/plain"
+
+	if v, ok := h.GetHeaderMapValue(int32(mapTypeHttpRequestHeaders), ":path"); !ok || v != "/foo" {
+		t.Errorf("GetHeaderMapValue(request headers, :path) = (%q, %v), want (/foo, true)", v, ok)
+	}
+	if v, ok := h.GetHeaderMapValue(int32(mapTypeHttpResponseHeaders), "content-type"); !ok || v != "text/plain" {
+		t.Errorf("GetHeaderMapValue(response headers, content-type) = (%q, %v), want (text/plain, true)", v, ok)
+	}
+	if _, ok := h.GetHeaderMapValue(int32(mapTypeHttpRequestHeaders), "missing"); ok {
+		t.Error("GetHeaderMapValue for an unset key = true, want false")
+	}
+}
+
+func TestHarnessSendLocalResponse(t *testing.T) {
+	h := NewHarness("wasmer")
+
+	h.SendLocalResponse(403, "forbidden", map[string]string{"x-reason": "denied"})
+
+	if h.LocalResponseSent == nil || h.LocalResponseSent.StatusCode != 403 || h.LocalResponseSent.Body != "forbidden" {
+		t.Errorf("LocalResponseSent = %+v, want status 403 body \"forbidden\"", h.LocalResponseSent)
+	}
+}
+
+func TestHarnessSetEffectiveContext(t *testing.T) {
+	h := NewHarness("wasmer")
+
+	if h.EffectiveContextId() != 0 {
+		t.Errorf("EffectiveContextId() before any call = %d, want 0", h.EffectiveContextId())
+	}
+
+	h.SetEffectiveContext(7)
+	if h.EffectiveContextId() != 7 {
+		t.Errorf("EffectiveContextId() = %d, want 7", h.EffectiveContextId())
+	}
+}
+
+func TestHarnessSharedDataAndQueue(t *testing.T) {
+	h := NewHarness("wasmer")
+
+	if _, ok := h.SharedData("key"); ok {
+		t.Error("SharedData before SetSharedData = true, want false")
+	}
+	h.SetSharedData("key", []byte("value"))
+	if data, ok := h.SharedData("key"); !ok || string(data) != "value" {
+		t.Errorf("SharedData(key) = (%q, %v), want (value, true)", data, ok)
+	}
+
+	h.EnqueueSharedQueue("q", []byte("a"))
+	h.EnqueueSharedQueue("q", []byte("b"))
+
+	data, ok := h.DequeueSharedQueue("q")
+	if !ok || string(data) != "a" {
+		t.Errorf("first DequeueSharedQueue(q) = (%q, %v), want (a, true)", data, ok)
+	}
+	data, ok = h.DequeueSharedQueue("q")
+	if !ok || string(data) != "b" {
+		t.Errorf("second DequeueSharedQueue(q) = (%q, %v), want (b, true)", data, ok)
+	}
+	if _, ok := h.DequeueSharedQueue("q"); ok {
+		t.Error("DequeueSharedQueue on an empty queue = true, want false")
+	}
+}
+
+func TestHarnessAdvanceClockDrivesVirtualClock(t *testing.T) {
+	h := NewHarness("wasmer")
+
+	ticks := 0
+	h.clock.SetTickPeriod(10, func() { ticks++ })
+	h.AdvanceClock(35)
+
+	if ticks != 3 {
+		t.Errorf("ticks = %d, want 3", ticks)
+	}
+}
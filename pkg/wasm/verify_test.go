@@ -0,0 +1,187 @@
+package wasm
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+func TestNoopVerifier(t *testing.T) {
+	if err := (NoopVerifier{}).Verify(PluginMetadata{Name: "anything"}, []byte("bytes")); err != nil {
+		t.Errorf("NoopVerifier.Verify() = %v, want nil", err)
+	}
+}
+
+func TestDigestVerifier(t *testing.T) {
+	wasmBytes := []byte("module bytes")
+	sum := sha256.Sum256(wasmBytes)
+	validSha256 := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name    string
+		v       DigestVerifier
+		wantErr bool
+	}{
+		{"blank digests skip checks", DigestVerifier{}, false},
+		{"matching sha256", DigestVerifier{ExpectedSha256: validSha256}, false},
+		{"mismatched sha256", DigestVerifier{ExpectedSha256: "deadbeef"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.v.Verify(PluginMetadata{}, wasmBytes)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, ErrVerificationFailed) {
+				t.Errorf("Verify() error = %v, want ErrVerificationFailed", err)
+			}
+		})
+	}
+}
+
+func TestConfigDigestVerifier(t *testing.T) {
+	wasmBytes := []byte("module bytes")
+	sum := sha256.Sum256(wasmBytes)
+	validSha256 := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name    string
+		meta    PluginMetadata
+		wantErr bool
+	}{
+		{"blank ExpectedSha256 skips the check", PluginMetadata{}, false},
+		{"matching ExpectedSha256", PluginMetadata{ExpectedSha256: validSha256}, false},
+		{"mismatched ExpectedSha256", PluginMetadata{ExpectedSha256: "deadbeef"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (ConfigDigestVerifier{}).Verify(tt.meta, wasmBytes)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, ErrVerificationFailed) {
+				t.Errorf("Verify() error = %v, want ErrVerificationFailed", err)
+			}
+		})
+	}
+}
+
+func TestSignatureVerifier(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	wasmBytes := []byte("module bytes")
+	sig := ed25519.Sign(priv, wasmBytes)
+
+	key := TrustedKey{Name: "k1", PublicKey: pub, AllowedPlugins: []string{"allowed-plugin"}}
+
+	tests := []struct {
+		name    string
+		v       SignatureVerifier
+		meta    PluginMetadata
+		bytes   []byte
+		wantErr bool
+	}{
+		{
+			name:    "valid signature for allowed plugin",
+			v:       SignatureVerifier{Keys: []TrustedKey{key}, Signatures: map[string][]byte{"allowed-plugin": sig}},
+			meta:    PluginMetadata{Name: "allowed-plugin"},
+			bytes:   wasmBytes,
+			wantErr: false,
+		},
+		{
+			name:    "no signature registered for plugin",
+			v:       SignatureVerifier{Keys: []TrustedKey{key}, Signatures: map[string][]byte{"other-plugin": sig}},
+			meta:    PluginMetadata{Name: "allowed-plugin"},
+			bytes:   wasmBytes,
+			wantErr: true,
+		},
+		{
+			name:    "key not allowed for this plugin name",
+			v:       SignatureVerifier{Keys: []TrustedKey{key}, Signatures: map[string][]byte{"other-plugin": sig}},
+			meta:    PluginMetadata{Name: "other-plugin"},
+			bytes:   wasmBytes,
+			wantErr: true,
+		},
+		{
+			name:    "signature does not match bytes",
+			v:       SignatureVerifier{Keys: []TrustedKey{key}, Signatures: map[string][]byte{"allowed-plugin": sig}},
+			meta:    PluginMetadata{Name: "allowed-plugin"},
+			bytes:   []byte("tampered bytes"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.v.Verify(tt.meta, tt.bytes)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignatureVerifierKeyWithNoAllowList(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	wasmBytes := []byte("module bytes")
+	sig := ed25519.Sign(priv, wasmBytes)
+
+	v := SignatureVerifier{
+		Keys:       []TrustedKey{{Name: "k1", PublicKey: pub}},
+		Signatures: map[string][]byte{"any-plugin": sig},
+	}
+
+	if err := v.Verify(PluginMetadata{Name: "any-plugin"}, wasmBytes); err != nil {
+		t.Errorf("Verify() = %v, want nil for a key with no AllowedPlugins restriction", err)
+	}
+}
+
+func TestChainVerifier(t *testing.T) {
+	pass := NoopVerifier{}
+	fail := DigestVerifier{ExpectedSha256: "deadbeef"}
+
+	if err := (ChainVerifier{pass, pass}).Verify(PluginMetadata{}, []byte("x")); err != nil {
+		t.Errorf("ChainVerifier of all-passing verifiers = %v, want nil", err)
+	}
+
+	if err := (ChainVerifier{pass, fail, pass}).Verify(PluginMetadata{}, []byte("x")); !errors.Is(err, ErrVerificationFailed) {
+		t.Errorf("ChainVerifier should fail fast on the first failing verifier, got %v", err)
+	}
+}
+
+func TestPluginMetadataOfCarriesExpectedSha256(t *testing.T) {
+	wasmBytes := []byte("module bytes")
+
+	config := v2.WasmPluginConfig{
+		PluginName: "p1",
+		VmConfig:   &v2.WasmVmConfig{Url: "https://example.com/p1.wasm", Sha256: "configured-digest"},
+	}
+
+	meta := pluginMetadataOf(config, wasmBytes)
+	if meta.ExpectedSha256 != "configured-digest" {
+		t.Errorf("ExpectedSha256 = %q, want %q (from config.VmConfig.Sha256)", meta.ExpectedSha256, "configured-digest")
+	}
+
+	noVmConfig := v2.WasmPluginConfig{PluginName: "p2"}
+	if meta := pluginMetadataOf(noVmConfig, wasmBytes); meta.ExpectedSha256 != "" {
+		t.Errorf("ExpectedSha256 = %q, want empty with no VmConfig", meta.ExpectedSha256)
+	}
+}
+
+func TestSetVerifierNilRestoresNoop(t *testing.T) {
+	SetVerifier(DigestVerifier{ExpectedSha256: "deadbeef"})
+	SetVerifier(nil)
+
+	if _, ok := currentVerifier().(NoopVerifier); !ok {
+		t.Errorf("currentVerifier() = %T, want NoopVerifier after SetVerifier(nil)", currentVerifier())
+	}
+}
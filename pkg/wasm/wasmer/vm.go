@@ -0,0 +1,243 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package wasmer adapts the cgo-based github.com/wasmerio/wasmer-go runtime
+// to the engine-agnostic types.WasmVM/WasmModule/WasmInstance interfaces so it
+// can be selected via v2.WasmVmConfig.Engine == "wasmer" (the default) and
+// mixed freely with other engines such as pkg/wasm/wazero.
+package wasmer
+
+import (
+	"errors"
+
+	"github.com/wasmerio/wasmer-go/wasmer"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+	mwasm "mosn.io/mosn/pkg/wasm"
+)
+
+func init() {
+	mwasm.RegisterWasmEngine(mwasm.EngineWasmer, newVM())
+}
+
+// vm implements types.WasmVM on top of wasmer-go. The engine and store are
+// shared across every module/instance created through this engine.
+type vm struct {
+	engine *wasmer.Engine
+	store  *wasmer.Store
+}
+
+func newVM() *vm {
+	engine := wasmer.NewEngine()
+	return &vm{engine: engine, store: wasmer.NewStore(engine)}
+}
+
+func (v *vm) Name() string {
+	return mwasm.EngineWasmer
+}
+
+func (v *vm) NewModule(wasmBytes []byte) types.WasmModule {
+	m, err := wasmer.NewModule(v.store, wasmBytes)
+	if err != nil {
+		log.DefaultLogger.Errorf("[wasm][wasmer] NewModule fail to compile module: %v", err)
+		return nil
+	}
+
+	return &module{vm: v, module: m}
+}
+
+// module implements types.WasmModule.
+type module struct {
+	vm     *vm
+	module *wasmer.Module
+}
+
+func (m *module) NewInstance() types.WasmInstance {
+	// i is forward-declared so the host functions below can close over it: it
+	// is only fully populated (i.instance set) once wasmer.NewInstance
+	// returns, but none of these host calls can be reached until the guest
+	// makes one from inside a later proxy_on_* export call, by which point
+	// that's already happened.
+	i := &instance{vm: m.vm}
+
+	importObject := wasmer.NewImportObject()
+	importObject.Register("env", i.hostFunctionImports())
+
+	inst, err := wasmer.NewInstance(m.module, importObject)
+	if err != nil {
+		// NewInstance returns a *wasmer.TrapError when the module's start
+		// function traps; a caller that wants to distinguish that case from
+		// other instantiation failures can type-assert the returned error.
+		var trapErr *wasmer.TrapError
+		if errors.As(err, &trapErr) {
+			log.DefaultLogger.Errorf("[wasm][wasmer] NewInstance start function trapped: %v", trapErr)
+		} else {
+			log.DefaultLogger.Errorf("[wasm][wasmer] NewInstance fail to instantiate: %v", err)
+		}
+		return nil
+	}
+
+	i.instance = inst
+	return i
+}
+
+// instance implements types.WasmInstance, forwarding exported-function calls
+// to the underlying wasmer-go instance. The per-ABI call wiring lives in
+// pkg/filter/stream/proxy-wasm, which only ever talks to this abstraction.
+type instance struct {
+	vm       *vm
+	instance *wasmer.Instance
+}
+
+// hostFunctionImports builds the "env" module every proxy-wasm SDK module
+// imports proxy_* host calls from. i need not be fully populated yet (see
+// NewInstance): these closures only run once the guest actually calls one of
+// them, which cannot happen before NewInstance has returned.
+func (i *instance) hostFunctionImports() map[string]wasmer.IntoExtern {
+	i32 := wasmer.I32
+
+	return map[string]wasmer.IntoExtern{
+		"proxy_log": wasmer.NewFunction(i.vm.store,
+			wasmer.NewFunctionType(wasmer.NewValueTypes(i32, i32, i32), wasmer.NewValueTypes(i32)),
+			func(args []wasmer.Value) ([]wasmer.Value, error) {
+				res := mwasm.ProxyLog(i, args[0].I32(), args[1].I32(), args[2].I32())
+				return []wasmer.Value{wasmer.NewI32(res)}, nil
+			}),
+		"proxy_get_header_map_value": wasmer.NewFunction(i.vm.store,
+			wasmer.NewFunctionType(wasmer.NewValueTypes(i32, i32, i32, i32, i32), wasmer.NewValueTypes(i32)),
+			func(args []wasmer.Value) ([]wasmer.Value, error) {
+				res := mwasm.ProxyGetHeaderMapValue(i, args[0].I32(), args[1].I32(), args[2].I32(), args[3].I32(), args[4].I32())
+				return []wasmer.Value{wasmer.NewI32(res)}, nil
+			}),
+		"proxy_send_local_response": wasmer.NewFunction(i.vm.store,
+			wasmer.NewFunctionType(wasmer.NewValueTypes(i32, i32, i32, i32, i32, i32, i32, i32), wasmer.NewValueTypes(i32)),
+			func(args []wasmer.Value) ([]wasmer.Value, error) {
+				res := mwasm.ProxySendLocalResponse(i,
+					args[0].I32(), args[1].I32(), args[2].I32(), args[3].I32(), args[4].I32(), args[5].I32(), args[6].I32(), args[7].I32())
+				return []wasmer.Value{wasmer.NewI32(res)}, nil
+			}),
+		"proxy_set_effective_context": wasmer.NewFunction(i.vm.store,
+			wasmer.NewFunctionType(wasmer.NewValueTypes(i32), wasmer.NewValueTypes(i32)),
+			func(args []wasmer.Value) ([]wasmer.Value, error) {
+				res := mwasm.ProxySetEffectiveContext(i, args[0].I32())
+				return []wasmer.Value{wasmer.NewI32(res)}, nil
+			}),
+	}
+}
+
+// ReadMemory/WriteMemory/Malloc implement mwasm.InstanceMemory, giving the
+// host call bodies in pkg/wasm raw access to this instance's linear memory
+// and its own proxy_on_memory_allocate export.
+func (i *instance) ReadMemory(offset, size int32) ([]byte, error) {
+	mem, err := i.instance.Exports.GetMemory("memory")
+	if err != nil {
+		return nil, err
+	}
+
+	data := mem.Data()
+	if offset < 0 || size < 0 || int(offset)+int(size) > len(data) {
+		return nil, errors.New("wasm: memory access out of bounds")
+	}
+
+	out := make([]byte, size)
+	copy(out, data[offset:int(offset)+int(size)])
+	return out, nil
+}
+
+func (i *instance) WriteMemory(offset int32, data []byte) error {
+	mem, err := i.instance.Exports.GetMemory("memory")
+	if err != nil {
+		return err
+	}
+
+	raw := mem.Data()
+	if offset < 0 || int(offset)+len(data) > len(raw) {
+		return errors.New("wasm: memory access out of bounds")
+	}
+
+	copy(raw[offset:], data)
+	return nil
+}
+
+func (i *instance) Malloc(size int32) (int32, error) {
+	allocFn, err := i.instance.Exports.GetFunction("proxy_on_memory_allocate")
+	if err != nil || allocFn == nil {
+		return 0, errors.New("wasm: module exports no proxy_on_memory_allocate allocator")
+	}
+
+	res, err := allocFn(size)
+	if err != nil {
+		return 0, err
+	}
+
+	ptr, _ := res.(int32)
+	return ptr, nil
+}
+
+func (i *instance) GetModule() types.WasmModule {
+	return nil
+}
+
+func (i *instance) Acquire() bool {
+	return true
+}
+
+func (i *instance) Release() {
+}
+
+// GetExportsFunc looks up the named export and returns a types.WasmFunction
+// that invokes it, converting any wasmer.TrapError into a *types.TrapError so
+// callers don't need to know which engine produced the instance.
+func (i *instance) GetExportsFunc(name string) (types.WasmFunction, error) {
+	fn, err := i.instance.Exports.GetFunction(name)
+	if err != nil || fn == nil {
+		return nil, errors.New("func " + name + " not found")
+	}
+
+	return wasmFunc(func(args ...int32) (int32, error) {
+		callArgs := make([]interface{}, len(args))
+		for idx, a := range args {
+			callArgs[idx] = a
+		}
+
+		res, err := fn(callArgs...)
+		if err != nil {
+			var trapErr *wasmer.TrapError
+			if errors.As(err, &trapErr) {
+				return 0, &types.TrapError{
+					Message:      trapErr.Message,
+					Module:       trapErr.Module,
+					FuncIndex:    trapErr.FuncIndex,
+					ModuleOffset: trapErr.ModuleOffset,
+					HasOrigin:    trapErr.HasOrigin,
+				}
+			}
+			return 0, err
+		}
+
+		i32, _ := res.(int32)
+		return i32, nil
+	}), nil
+}
+
+// wasmFunc adapts a plain Go closure to types.WasmFunction.
+type wasmFunc func(args ...int32) (int32, error)
+
+func (f wasmFunc) Call(args ...int32) (int32, error) {
+	return f(args...)
+}
@@ -0,0 +1,259 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package wazero adapts the pure-Go github.com/tetratelabs/wazero runtime to
+// the engine-agnostic types.WasmVM/WasmModule/WasmInstance interfaces, giving
+// operators a cgo-free alternative to pkg/wasm/wasmer. It is selected by
+// setting v2.WasmVmConfig.Engine to "wazero"; the proxywasm filter layer is
+// unaware of which engine backs a given plugin.
+package wazero
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/wasi_snapshot_preview1"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+	mwasm "mosn.io/mosn/pkg/wasm"
+)
+
+func init() {
+	mwasm.RegisterWasmEngine(mwasm.EngineWazero, newVM())
+}
+
+// instancesByModule maps a wazero api.Module (the calling module instance
+// wazero's host functions are handed) back to the *instance wrapper it
+// belongs to, so the single shared "env" host module below can resolve which
+// instance's memory/allocator a given proxy_* call should act against.
+// NewInstance populates an entry once instantiation succeeds; Release clears
+// it.
+var instancesByModule sync.Map // api.Module -> *instance
+
+// vm implements types.WasmVM on top of wazero. A single runtime is shared by
+// every module/instance created through this engine, matching wazero's own
+// recommendation to reuse a runtime across compilations.
+type vm struct {
+	ctx     context.Context
+	runtime wazero.Runtime
+}
+
+func newVM() *vm {
+	ctx := context.Background()
+	rt := wazero.NewRuntime(ctx)
+
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, rt); err != nil {
+		log.DefaultLogger.Errorf("[wasm][wazero] newVM fail to instantiate wasi: %v", err)
+	}
+
+	if _, err := registerHostModule(ctx, rt); err != nil {
+		log.DefaultLogger.Errorf("[wasm][wazero] newVM fail to instantiate env host module: %v", err)
+	}
+
+	return &vm{ctx: ctx, runtime: rt}
+}
+
+// registerHostModule instantiates the "env" module every proxy-wasm SDK
+// module imports proxy_* host calls from. It is built once per runtime
+// (unlike pkg/wasm/wasmer, which builds a fresh import object per instance)
+// because wazero host functions are handed the calling api.Module directly,
+// so instancesByModule is enough to recover which *instance a call belongs
+// to without needing a separate import object per instance.
+func registerHostModule(ctx context.Context, rt wazero.Runtime) (api.Closer, error) {
+	instanceOf := func(mod api.Module) *instance {
+		v, ok := instancesByModule.Load(mod)
+		if !ok {
+			return nil
+		}
+		i, _ := v.(*instance)
+		return i
+	}
+
+	return rt.NewHostModuleBuilder("env").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, logLevel, messageData, messageSize uint32) uint32 {
+			i := instanceOf(mod)
+			if i == nil {
+				return uint32(mwasm.ResultInternalFailure)
+			}
+			return uint32(mwasm.ProxyLog(i, int32(logLevel), int32(messageData), int32(messageSize)))
+		}).
+		Export("proxy_log").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, mapType, keyData, keySize, returnValueData, returnValueSize uint32) uint32 {
+			i := instanceOf(mod)
+			if i == nil {
+				return uint32(mwasm.ResultInternalFailure)
+			}
+			return uint32(mwasm.ProxyGetHeaderMapValue(i, int32(mapType), int32(keyData), int32(keySize), int32(returnValueData), int32(returnValueSize)))
+		}).
+		Export("proxy_get_header_map_value").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, statusCode, detailsData, detailsSize, bodyData, bodySize, headersData, headersSize, grpcStatus uint32) uint32 {
+			i := instanceOf(mod)
+			if i == nil {
+				return uint32(mwasm.ResultInternalFailure)
+			}
+			return uint32(mwasm.ProxySendLocalResponse(i, int32(statusCode), int32(detailsData), int32(detailsSize), int32(bodyData), int32(bodySize), int32(headersData), int32(headersSize), int32(grpcStatus)))
+		}).
+		Export("proxy_send_local_response").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, contextId uint32) uint32 {
+			i := instanceOf(mod)
+			if i == nil {
+				return uint32(mwasm.ResultInternalFailure)
+			}
+			return uint32(mwasm.ProxySetEffectiveContext(i, int32(contextId)))
+		}).
+		Export("proxy_set_effective_context").
+		Instantiate(ctx)
+}
+
+func (v *vm) Name() string {
+	return mwasm.EngineWazero
+}
+
+func (v *vm) NewModule(wasmBytes []byte) types.WasmModule {
+	compiled, err := v.runtime.CompileModule(v.ctx, wasmBytes)
+	if err != nil {
+		log.DefaultLogger.Errorf("[wasm][wazero] NewModule fail to compile module: %v", err)
+		return nil
+	}
+
+	return &module{vm: v, compiled: compiled}
+}
+
+// module implements types.WasmModule.
+type module struct {
+	vm       *vm
+	compiled wazero.CompiledModule
+}
+
+func (m *module) NewInstance() types.WasmInstance {
+	cfg := wazero.NewModuleConfig().WithStartFunctions("_initialize")
+
+	mod, err := m.vm.runtime.InstantiateModule(m.vm.ctx, m.compiled, cfg)
+	if err != nil {
+		log.DefaultLogger.Errorf("[wasm][wazero] NewInstance fail to instantiate: %v", err)
+		return nil
+	}
+
+	i := &instance{vm: m.vm, module: mod}
+	instancesByModule.Store(mod, i)
+	return i
+}
+
+// instance implements types.WasmInstance, forwarding exported-function calls
+// to the underlying wazero module instance.
+type instance struct {
+	vm     *vm
+	module api.Module
+}
+
+func (i *instance) GetModule() types.WasmModule {
+	return nil
+}
+
+func (i *instance) Acquire() bool {
+	return true
+}
+
+func (i *instance) Release() {
+	if i.module != nil {
+		instancesByModule.Delete(i.module)
+		_ = i.module.Close(i.vm.ctx)
+	}
+}
+
+// ReadMemory/WriteMemory/Malloc implement mwasm.InstanceMemory, giving the
+// host call bodies in pkg/wasm raw access to this instance's linear memory
+// and its own proxy_on_memory_allocate export.
+func (i *instance) ReadMemory(offset, size int32) ([]byte, error) {
+	data, ok := i.module.Memory().Read(uint32(offset), uint32(size))
+	if !ok {
+		return nil, errors.New("wasm: memory access out of bounds")
+	}
+
+	out := make([]byte, len(data))
+	copy(out, data)
+	return out, nil
+}
+
+func (i *instance) WriteMemory(offset int32, data []byte) error {
+	if !i.module.Memory().Write(uint32(offset), data) {
+		return errors.New("wasm: memory access out of bounds")
+	}
+	return nil
+}
+
+func (i *instance) Malloc(size int32) (int32, error) {
+	allocFn := i.module.ExportedFunction("proxy_on_memory_allocate")
+	if allocFn == nil {
+		return 0, errors.New("wasm: module exports no proxy_on_memory_allocate allocator")
+	}
+
+	res, err := allocFn.Call(i.vm.ctx, uint64(uint32(size)))
+	if err != nil {
+		return 0, err
+	}
+	if len(res) == 0 {
+		return 0, errors.New("wasm: proxy_on_memory_allocate returned no value")
+	}
+
+	return int32(uint32(res[0])), nil
+}
+
+// GetExportsFunc looks up the named export and returns a types.WasmFunction
+// that invokes it, converting a trapped call into a *types.TrapError so
+// callers don't need to know which engine produced the instance. wazero
+// surfaces a trap as a plain error carrying the wasm stack trace in its
+// message rather than structured frame data, so FuncIndex/ModuleOffset are
+// left zero and HasOrigin false; Message still carries the useful detail.
+func (i *instance) GetExportsFunc(name string) (types.WasmFunction, error) {
+	fn := i.module.ExportedFunction(name)
+	if fn == nil {
+		return nil, errors.New("func " + name + " not found")
+	}
+
+	return wasmFunc(func(args ...int32) (int32, error) {
+		callArgs := make([]uint64, len(args))
+		for idx, a := range args {
+			callArgs[idx] = uint64(uint32(a))
+		}
+
+		res, err := fn.Call(i.vm.ctx, callArgs...)
+		if err != nil {
+			return 0, &types.TrapError{Message: err.Error()}
+		}
+
+		if len(res) == 0 {
+			return 0, nil
+		}
+		return int32(uint32(res[0])), nil
+	}), nil
+}
+
+// wasmFunc adapts a plain Go closure to types.WasmFunction.
+type wasmFunc func(args ...int32) (int32, error)
+
+func (f wasmFunc) Call(args ...int32) (int32, error) {
+	return f(args...)
+}
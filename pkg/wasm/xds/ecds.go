@@ -0,0 +1,186 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package xds translates Envoy Extension Config Discovery Service (ECDS)
+// resources into calls against pkg/wasm.GetWasmManager, so a control plane
+// that already knows how to push envoy.extensions.filters.http.wasm.v3.Wasm /
+// envoy.extensions.wasm.v3.PluginConfig resources (Istio, for example) can
+// drive MOSN's wasm plugins the same way it drives Envoy's, instead of
+// requiring static config or a MOSN-specific API.
+//
+// This package deliberately does not depend on a generated envoy protobuf
+// client: ECDSResource below is the minimal shape of the fields this
+// package needs out of an ECDS Resource (vm_config, code source, remote
+// sha256, plugin configuration, environment variables), decoded by the
+// caller's xDS client from whichever envoy.extensions.wasm.v3.PluginConfig
+// message it already unmarshals. This keeps the mapping testable without a
+// live control plane and lets the Manager below be driven by either an ADS
+// multiplexed stream or a standalone ECDS endpoint.
+package xds
+
+import (
+	"errors"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	mwasm "mosn.io/mosn/pkg/wasm"
+)
+
+var ErrResourceMissingName = errors.New("wasm: ecds resource without a plugin name")
+
+// CodeSource is the subset of envoy.config.core.v3.AsyncDataSource /
+// HttpUri / RemoteDataSource fields this package maps onto v2.VmConfig.
+type CodeSource struct {
+	// LocalFilename is set for envoy's local AsyncDataSource.
+	LocalFilename string
+
+	// RemoteURI/RemoteSha256 are set for envoy's remote AsyncDataSource
+	// (either a plain HTTP(S) URI or, when it names a registry, an OCI
+	// reference consumed by pkg/wasm/store). RemoteSha256 is forwarded to
+	// v2.WasmVmConfig.Sha256, where pkg/wasm.ConfigDigestVerifier can enforce
+	// it against the fetched module's bytes if installed via SetVerifier.
+	RemoteURI    string
+	RemoteSha256 string
+
+	// AllowPrecompiled mirrors Envoy's vm_config.allow_precompiled. It is
+	// forwarded to v2.WasmVmConfig.AllowPrecompiled, but MOSN has no
+	// precompiled-module cache today, so ToWasmPluginConfig just logs a
+	// warning when it's set rather than changing how the module is loaded.
+	AllowPrecompiled bool
+}
+
+// VMConfig is the subset of envoy.extensions.wasm.v3.VmConfig this package
+// understands.
+type VMConfig struct {
+	Runtime     string // e.g. "envoy.wasm.runtime.v8" / "envoy.wasm.runtime.null"; mapped to a pkg/wasm engine name
+	Code        CodeSource
+	Environment map[string]string
+}
+
+// ECDSResource is the minimal shape this package needs from an
+// envoy.extensions.wasm.v3.PluginConfig / Wasm ECDS resource.
+type ECDSResource struct {
+	Name              string
+	VMConfig          VMConfig
+	ConfigurationJSON []byte
+	InstanceNum       int
+}
+
+// runtimeToEngine maps Envoy's wasm runtime identifiers onto the pkg/wasm
+// engine names registered via mwasm.RegisterWasmEngine. Unknown runtimes,
+// including "envoy.wasm.runtime.null" (a builtin, non-wasm Envoy runtime
+// with no MOSN equivalent), fall back to the default wasmer engine.
+var runtimeToEngine = map[string]string{
+	"envoy.wasm.runtime.v8":       mwasm.EngineWasmer,
+	"envoy.wasm.runtime.wamr":     mwasm.EngineWasmer,
+	"envoy.wasm.runtime.wasmtime": mwasm.EngineWazero,
+	"envoy.wasm.runtime.wazero":   mwasm.EngineWazero,
+}
+
+// ToWasmPluginConfig translates an ECDS resource into the v2.WasmPluginConfig
+// pkg/wasm.GetWasmManager().AddOrUpdateWasm expects.
+func ToWasmPluginConfig(res ECDSResource) (v2.WasmPluginConfig, error) {
+	if res.Name == "" {
+		return v2.WasmPluginConfig{}, ErrResourceMissingName
+	}
+
+	engine, ok := runtimeToEngine[res.VMConfig.Runtime]
+	if !ok {
+		engine = mwasm.EngineWasmer
+	}
+
+	if res.VMConfig.Code.AllowPrecompiled {
+		log.DefaultLogger.Warnf("[wasm][xds] ecds resource %v sets allow_precompiled, which MOSN has no "+
+			"precompiled-module cache for; the module will be compiled from source as usual", res.Name)
+	}
+
+	vmConfig := &v2.WasmVmConfig{
+		Engine:           engine,
+		Path:             res.VMConfig.Code.LocalFilename,
+		Url:              res.VMConfig.Code.RemoteURI,
+		Sha256:           res.VMConfig.Code.RemoteSha256,
+		AllowPrecompiled: res.VMConfig.Code.AllowPrecompiled,
+		Environment:      res.VMConfig.Environment,
+	}
+
+	return v2.WasmPluginConfig{
+		PluginName:   res.Name,
+		InstanceNum:  res.InstanceNum,
+		VmConfig:     vmConfig,
+		PluginConfig: res.ConfigurationJSON,
+	}, nil
+}
+
+// Manager subscribes to ECDS resources (via either an ADS-multiplexed stream
+// or a standalone ECDS endpoint, depending on how client was built) and
+// reconciles every update/removal against pkg/wasm.GetWasmManager.
+type Manager struct {
+	client ECDSClient
+}
+
+// ECDSClient is the subscription surface this package needs from MOSN's xDS
+// client: a stream of ECDS resource snapshots (updates) and removed resource
+// names (removals), for either an ADS multiplexed stream or a standalone
+// ECDS endpoint.
+type ECDSClient interface {
+	Subscribe(onUpdate func(resources []ECDSResource), onRemove func(names []string)) error
+	Close() error
+}
+
+// NewManager builds a Manager that reconciles ECDS updates delivered by
+// client against pkg/wasm.GetWasmManager.
+func NewManager(client ECDSClient) *Manager {
+	return &Manager{client: client}
+}
+
+// Start begins subscribing to ECDS updates. It returns once the initial
+// Subscribe call completes; updates are applied asynchronously as they
+// arrive from client.
+func (m *Manager) Start() error {
+	return m.client.Subscribe(m.onUpdate, m.onRemove)
+}
+
+// Stop tears down the underlying client subscription.
+func (m *Manager) Stop() error {
+	return m.client.Close()
+}
+
+func (m *Manager) onUpdate(resources []ECDSResource) {
+	manager := mwasm.GetWasmManager()
+
+	for _, res := range resources {
+		config, err := ToWasmPluginConfig(res)
+		if err != nil {
+			log.DefaultLogger.Errorf("[wasm][xds] onUpdate fail to translate ecds resource: %v", err)
+			continue
+		}
+
+		if err := manager.AddOrUpdateWasm(config); err != nil {
+			log.DefaultLogger.Errorf("[wasm][xds] onUpdate fail to apply ecds resource %v: %v", res.Name, err)
+		}
+	}
+}
+
+func (m *Manager) onRemove(names []string) {
+	manager := mwasm.GetWasmManager()
+
+	for _, name := range names {
+		if err := manager.UninstallWasmPluginByName(name); err != nil {
+			log.DefaultLogger.Errorf("[wasm][xds] onRemove fail to uninstall plugin %v: %v", name, err)
+		}
+	}
+}
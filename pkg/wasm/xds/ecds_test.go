@@ -0,0 +1,93 @@
+package xds
+
+import (
+	"errors"
+	"testing"
+
+	mwasm "mosn.io/mosn/pkg/wasm"
+)
+
+func TestToWasmPluginConfigMissingName(t *testing.T) {
+	_, err := ToWasmPluginConfig(ECDSResource{})
+	if !errors.Is(err, ErrResourceMissingName) {
+		t.Errorf("ToWasmPluginConfig() error = %v, want ErrResourceMissingName", err)
+	}
+}
+
+func TestToWasmPluginConfigMapsFields(t *testing.T) {
+	res := ECDSResource{
+		Name: "plugin1",
+		VMConfig: VMConfig{
+			Runtime: "envoy.wasm.runtime.wasmtime",
+			Code: CodeSource{
+				RemoteURI:        "https://example.com/plugin1.wasm",
+				RemoteSha256:     "deadbeef",
+				AllowPrecompiled: true,
+			},
+			Environment: map[string]string{"FOO": "bar"},
+		},
+		ConfigurationJSON: []byte(`{"key":"value"}`),
+		InstanceNum:       4,
+	}
+
+	config, err := ToWasmPluginConfig(res)
+	if err != nil {
+		t.Fatalf("ToWasmPluginConfig() error = %v, want nil", err)
+	}
+
+	if config.PluginName != "plugin1" {
+		t.Errorf("PluginName = %q, want %q", config.PluginName, "plugin1")
+	}
+	if config.InstanceNum != 4 {
+		t.Errorf("InstanceNum = %d, want 4", config.InstanceNum)
+	}
+	if string(config.PluginConfig) != `{"key":"value"}` {
+		t.Errorf("PluginConfig = %q, want %q", config.PluginConfig, `{"key":"value"}`)
+	}
+
+	if config.VmConfig == nil {
+		t.Fatalf("VmConfig = nil, want non-nil")
+	}
+	if config.VmConfig.Engine != mwasm.EngineWazero {
+		t.Errorf("VmConfig.Engine = %q, want %q (envoy.wasm.runtime.wasmtime maps to wazero)", config.VmConfig.Engine, mwasm.EngineWazero)
+	}
+	if config.VmConfig.Url != "https://example.com/plugin1.wasm" {
+		t.Errorf("VmConfig.Url = %q, want %q", config.VmConfig.Url, "https://example.com/plugin1.wasm")
+	}
+	if config.VmConfig.Sha256 != "deadbeef" {
+		t.Errorf("VmConfig.Sha256 = %q, want %q", config.VmConfig.Sha256, "deadbeef")
+	}
+	if !config.VmConfig.AllowPrecompiled {
+		t.Error("VmConfig.AllowPrecompiled = false, want true")
+	}
+	if config.VmConfig.Environment["FOO"] != "bar" {
+		t.Errorf("VmConfig.Environment[FOO] = %q, want %q", config.VmConfig.Environment["FOO"], "bar")
+	}
+}
+
+func TestToWasmPluginConfigUnknownRuntimeDefaultsToWasmer(t *testing.T) {
+	res := ECDSResource{Name: "plugin1", VMConfig: VMConfig{Runtime: "envoy.wasm.runtime.null"}}
+
+	config, err := ToWasmPluginConfig(res)
+	if err != nil {
+		t.Fatalf("ToWasmPluginConfig() error = %v, want nil", err)
+	}
+	if config.VmConfig.Engine != mwasm.EngineWasmer {
+		t.Errorf("VmConfig.Engine = %q, want %q for an unknown/null runtime", config.VmConfig.Engine, mwasm.EngineWasmer)
+	}
+}
+
+func TestToWasmPluginConfigLocalFilename(t *testing.T) {
+	res := ECDSResource{
+		Name:     "plugin1",
+		VMConfig: VMConfig{Code: CodeSource{LocalFilename: "/opt/plugins/plugin1.wasm"}},
+	}
+
+	config, err := ToWasmPluginConfig(res)
+	if err != nil {
+		t.Fatalf("ToWasmPluginConfig() error = %v, want nil", err)
+	}
+	if config.VmConfig.Path != "/opt/plugins/plugin1.wasm" {
+		t.Errorf("VmConfig.Path = %q, want %q", config.VmConfig.Path, "/opt/plugins/plugin1.wasm")
+	}
+}
@@ -46,8 +46,7 @@ func NewInstance(module *Module, imports *ImportObject) (*Instance, error) {
 	}
 
 	if traps != nil {
-		// TODO(jubianchi): Implement this properly
-		return nil, newErrorWith("trapped! to do")
+		return nil, newTrapError(traps, "")
 	}
 
 	output := &Instance{
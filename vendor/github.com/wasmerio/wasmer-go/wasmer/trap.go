@@ -0,0 +1,66 @@
+package wasmer
+
+// #include <wasmer_wasm.h>
+import "C"
+import (
+	"fmt"
+)
+
+// TrapError is returned by NewInstance and by individual exported-function
+// calls when execution traps (an unreachable instruction, an out-of-bounds
+// memory access, a stack overflow, ...). It carries enough of the trap to let
+// callers log or react to it without re-deriving it from a generic error
+// string.
+type TrapError struct {
+	// Message is the trap's human-readable message, as produced by the
+	// engine (e.g. "unreachable").
+	Message string
+
+	// Module is the name of the module in which the trap originated, when
+	// the engine was able to attribute it to one.
+	Module string
+
+	// FuncIndex is the index, within Module, of the function that trapped.
+	FuncIndex uint32
+
+	// ModuleOffset is the byte offset of the trapping instruction within
+	// Module's code section.
+	ModuleOffset uint
+
+	HasOrigin bool
+}
+
+func (e *TrapError) Error() string {
+	if !e.HasOrigin {
+		return fmt.Sprintf("wasm trap: %s", e.Message)
+	}
+
+	return fmt.Sprintf("wasm trap: %s (module %q func #%d offset %#x)", e.Message, e.Module, e.FuncIndex, e.ModuleOffset)
+}
+
+// newTrapError builds a *TrapError from a raw wasm_trap_t, reading its
+// message and, when available, the innermost frame of its origin. trap is
+// freed before returning.
+func newTrapError(trap *C.wasm_trap_t, moduleName string) *TrapError {
+	defer C.wasm_trap_delete(trap)
+
+	var message C.wasm_message_t
+	C.wasm_trap_message(trap, &message)
+	defer C.wasm_byte_vec_delete(&message)
+
+	trapErr := &TrapError{
+		Message: C.GoStringN(message.data, C.int(message.size)),
+		Module:  moduleName,
+	}
+
+	origin := C.wasm_trap_origin(trap)
+	if origin != nil {
+		defer C.wasm_frame_delete(origin)
+
+		trapErr.HasOrigin = true
+		trapErr.FuncIndex = uint32(C.wasm_frame_func_index(origin))
+		trapErr.ModuleOffset = uint(C.wasm_frame_module_offset(origin))
+	}
+
+	return trapErr
+}